@@ -0,0 +1,250 @@
+/*
+ * build.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+// A sortedBuilder adds strings, in sorted order, to a trie.  Since each string
+// shares a path with its predecessor up to the point at which they differ,
+// the builder remembers that path and resumes from it rather than descending
+// from the root each time.
+type sortedBuilder struct {
+	trie  *Trie
+	prev  string
+	path  []*Trie // path[i] is the node at the end of the first i runes of prev.
+	runes []rune  // the runes of prev.
+}
+
+func newSortedBuilder(t *Trie) *sortedBuilder {
+	b := new(sortedBuilder)
+	b.trie = t
+	b.path = []*Trie{t}
+	return b
+}
+
+// Internal function: adds s, which must not sort before the previous string.
+func (b *sortedBuilder) add(s string) error {
+	if len(s) == 0 || s == b.prev {
+		return nil
+	}
+	if s < b.prev {
+		return fmt.Errorf("trie: input is not sorted: '%s' follows '%s'", s, b.prev)
+	}
+
+	// find the length of the path shared with the previous string
+	runes := []rune(s)
+	n := 0
+	for n < len(runes) && n < len(b.runes) && runes[n] == b.runes[n] {
+		n++
+	}
+
+	b.path = b.path[:n+1]
+	node := b.path[n]
+	created := 0
+	for _, r := range runes[n:] {
+		child, ok := node.children[r]
+		if !ok {
+			child = newNode()
+			node.children[r] = child
+			created++
+		}
+		node = child
+		b.path = append(b.path, node)
+	}
+	b.trie.setLeaf(node)
+
+	// the new nodes end the path, and each node on it gains those below it
+	last := len(b.path) - 1
+	for i, pn := range b.path {
+		if below := last - i; below < created {
+			pn.size += below
+		} else {
+			pn.size += created
+		}
+	}
+
+	b.prev, b.runes = s, runes
+	return nil
+}
+
+// a line read from one of the inputs to BuildFromSortedReaders
+type sortedLine struct {
+	text  string
+	input int
+}
+
+// a min-heap of the next line from each input
+type sortedLineHeap []sortedLine
+
+func (h sortedLineHeap) Len() int            { return len(h) }
+func (h sortedLineHeap) Less(i, j int) bool  { return h[i].text < h[j].text }
+func (h sortedLineHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *sortedLineHeap) Push(x interface{}) { *h = append(*h, x.(sortedLine)) }
+func (h *sortedLineHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// BuildFromSortedReaders builds a trie from the lines of several inputs, each
+// of which must already be sorted.  The inputs are merged, so the trie can be
+// built in order without descending from the root for every string, and
+// duplicate lines are only added once.  Empty lines are ignored.
+func BuildFromSortedReaders(readers ...io.Reader) (*Trie, error) {
+	scanners := make([]*bufio.Scanner, len(readers))
+	h := &sortedLineHeap{}
+
+	// read the next line from input i onto the heap, checking it is in order
+	next := func(i int, prev string) error {
+		for scanners[i].Scan() {
+			line := scanners[i].Text()
+			if len(line) == 0 {
+				continue
+			}
+			if line < prev {
+				return fmt.Errorf("trie: input %d is not sorted: '%s' follows '%s'", i, line, prev)
+			}
+			heap.Push(h, sortedLine{line, i})
+			return nil
+		}
+		return scanners[i].Err()
+	}
+
+	for i, r := range readers {
+		scanners[i] = bufio.NewScanner(r)
+		if err := next(i, ``); err != nil {
+			return nil, err
+		}
+	}
+
+	t := NewTrie()
+	b := newSortedBuilder(t)
+	for h.Len() > 0 {
+		line := heap.Pop(h).(sortedLine)
+		if err := b.add(line.text); err != nil {
+			return nil, err
+		}
+		if err := next(line.input, line.text); err != nil {
+			return nil, err
+		}
+	}
+
+	return t, nil
+}
+
+// BuildConcurrent builds a trie from strs using up to workers goroutines, or
+// one per CPU if workers isn't positive.  The strings are divided by their
+// first rune, each group being added to its own sub-trie, so no two
+// goroutines share any node.  The result is the same as adding strs in order
+// with AddAll, including the order in which the members were added.
+func BuildConcurrent(strs []string, workers int) *Trie {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	// group the indices of the strings by first rune, keeping their order
+	groups := make(map[rune][]int)
+	for i, s := range strs {
+		if len(s) == 0 {
+			continue
+		}
+		r, _ := utf8.DecodeRuneInString(s)
+		groups[r] = append(groups[r], i)
+	}
+	runes := make([]rune, 0, len(groups))
+	for r := range groups {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	// firsts[i] is the leaf which strs[i] made a member, if it was the first to do so; each
+	// goroutine only writes the indices of its own groups
+	firsts := make([]*Trie, len(strs))
+
+	shards := make([]*Trie, len(runes))
+	next := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(runes); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range next {
+				shard := NewTrie()
+				for _, j := range groups[runes[i]] {
+					leaf, _ := shard.addString(strs[j], 0)
+					if leaf.leaf {
+						leaf.refs++
+						continue
+					}
+					shard.setLeaf(leaf)
+					firsts[j] = leaf
+				}
+				shards[i] = shard
+			}
+		}()
+	}
+	for i := range runes {
+		next <- i
+	}
+	close(next)
+	wg.Wait()
+
+	t := NewTrie()
+	for i, r := range runes {
+		t.children[r] = shards[i].children[r]
+	}
+	t.resize()
+
+	// number the members in the order a sequential build would have
+	for _, leaf := range firsts {
+		if leaf != nil {
+			t.lastSeq++
+			leaf.seq = t.lastSeq
+		}
+	}
+	return t
+}
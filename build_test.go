@@ -0,0 +1,130 @@
+/*
+ * build_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuildFromSortedReaders(t *testing.T) {
+	inputs := []string{
+		"apple\nbanana\ncherry\ncherrystone\n",
+		"apricot\nbanana\ndate\n\nélan\n",
+		"a\napp\napplesauce\nzebra",
+	}
+
+	readers := []io.Reader{}
+	expected := NewTrie()
+	for _, in := range inputs {
+		readers = append(readers, strings.NewReader(in))
+		for _, w := range strings.Split(in, "\n") {
+			expected.AddString(w)
+		}
+	}
+
+	trie, err := BuildFromSortedReaders(readers...)
+	if err != nil {
+		t.Fatalf("Failed to build trie: %s", err)
+	}
+	if !reflect.DeepEqual(trie.Members(), expected.Members()) {
+		t.Errorf("expected members %v, found %v", expected.Members(), trie.Members())
+	}
+	if trie.Size() != expected.Size() {
+		t.Errorf("expected %d nodes, found %d", expected.Size(), trie.Size())
+	}
+
+	_, err = BuildFromSortedReaders(strings.NewReader("b\na\n"), strings.NewReader("c\n"))
+	if err == nil {
+		t.Error("building from an unsorted input should fail")
+	}
+}
+
+func concurrentWords() []string {
+	words := []string{}
+	for i := 0; i < 2000; i++ {
+		words = append(words, fmt.Sprintf("%c%x", 'a'+rune(i%26), i*7919))
+		if i%10 == 0 {
+			words = append(words, fmt.Sprintf("ñ%d", i), fmt.Sprintf("%c", 'a'+rune(i%26)))
+		}
+	}
+	return words
+}
+
+func TestBuildConcurrent(t *testing.T) {
+	words := concurrentWords()
+	sequential := NewTrie()
+	sequential.AddAll(words)
+
+	for _, workers := range []int{0, 1, 4, 100} {
+		built := BuildConcurrent(words, workers)
+		if !built.Equal(sequential) {
+			t.Errorf("%d workers: expected the same trie as a sequential build", workers)
+		}
+		if built.Size() != sequential.Size() || built.RefCount(`a`) != sequential.RefCount(`a`) {
+			t.Errorf("%d workers: expected %d nodes, found %d", workers, sequential.Size(), built.Size())
+		}
+		if !reflect.DeepEqual(built.MembersByInsertionOrder(), sequential.MembersByInsertionOrder()) {
+			t.Errorf("%d workers: expected the members to keep their insertion order", workers)
+		}
+	}
+
+	if built := BuildConcurrent(nil, 4); built.Size() != 0 {
+		t.Errorf("expected an empty trie, found %v", built.Members())
+	}
+}
+
+func BenchmarkBuildConcurrent(b *testing.B) {
+	words := concurrentWords()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		BuildConcurrent(words, 0)
+	}
+}
+
+func BenchmarkBuildSequential(b *testing.B) {
+	words := concurrentWords()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		NewTrie().AddAll(words)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+ * compact.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// A CompactTrie is a read-only radix tree built from a Trie by Compact.  Each
+// run of nodes with a single child and no member is collapsed into one edge
+// labelled with the runes along it, so a CompactTrie holds a node only where
+// the original branches or a member ends.  This saves a great deal of memory
+// where members share long prefixes, such as file paths, at the cost of
+// comparing labels rather than single runes while searching.
+type CompactTrie struct {
+	leaf  bool          // whether the node is a leaf (the end of an input string).
+	value interface{}   // the value associated with the string up to this leaf node.
+	edges []compactEdge // the edges to this node's children, in label order.
+}
+
+// an edge to a child of a CompactTrie node
+type compactEdge struct {
+	label string       // the runes leading to the child; never empty.
+	node  *CompactTrie // the child.
+}
+
+// Internal function used by Compact(): compacts this node and its descendants.
+func (p *Trie) compact() *CompactTrie {
+	c := &CompactTrie{leaf: p.leaf, value: p.value}
+	c.edges = make([]compactEdge, 0, len(p.children))
+
+	for _, r := range p.sortedRunes() {
+		var label strings.Builder
+		label.WriteRune(r)
+
+		// follow the run of nodes which neither branch nor end a member
+		n := p.children[r]
+		for len(n.children) == 1 && !n.leaf {
+			for cr, child := range n.children {
+				label.WriteRune(cr)
+				n = child
+			}
+		}
+		c.edges = append(c.edges, compactEdge{label.String(), n.compact()})
+	}
+
+	return c
+}
+
+// Compact returns a CompactTrie holding the same members and values as the
+// trie.  Later changes to the trie are not reflected in the CompactTrie.
+func (p *Trie) Compact() *CompactTrie {
+	return p.compact()
+}
+
+// Internal function: returns the edge from this node whose label begins with the first
+// rune of s, or nil if there is none.
+func (c *CompactTrie) edge(s string) *compactEdge {
+	_, size := utf8.DecodeRuneInString(s)
+	first := s[:size]
+
+	i := sort.Search(len(c.edges), func(i int) bool { return c.edges[i].label >= first })
+	if i < len(c.edges) && strings.HasPrefix(c.edges[i].label, first) {
+		return &c.edges[i]
+	}
+	return nil
+}
+
+// Internal function: returns the node reached by following s, along with the string
+// leading to it, which is longer than s if s ends partway along an edge.  Returns nil if
+// s is not a path in the trie.
+func (c *CompactTrie) locate(s string) (*CompactTrie, string) {
+	pos := 0
+	for pos < len(s) {
+		rest := s[pos:]
+		e := c.edge(rest)
+		if e == nil {
+			return nil, ``
+		}
+
+		switch {
+		case strings.HasPrefix(rest, e.label):
+			pos += len(e.label)
+			c = e.node
+		case strings.HasPrefix(e.label, rest):
+			return e.node, s + e.label[len(rest):]
+		default:
+			return nil, ``
+		}
+	}
+	return c, s
+}
+
+// Contains tests for the inclusion of a particular string in the trie.
+func (c *CompactTrie) Contains(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	n, path := c.locate(s)
+	return n != nil && path == s && n.leaf
+}
+
+// GetValue returns the value associated with the given string.  The bool is
+// false if s is not a member of the trie.
+func (c *CompactTrie) GetValue(s string) (interface{}, bool) {
+	if !c.Contains(s) {
+		return nil, false
+	}
+	n, _ := c.locate(s)
+	return n.value, true
+}
+
+// Internal function used by PrefixSearch() and Members(): appends, in order, the members
+// at or below this node, where prefix is the string leading to it.
+func (c *CompactTrie) appendMembers(prefix string, members []string) []string {
+	if c.leaf {
+		members = append(members, prefix)
+	}
+	for _, e := range c.edges {
+		members = e.node.appendMembers(prefix+e.label, members)
+	}
+	return members
+}
+
+// PrefixSearch retrieves, in order, all member strings which begin with
+// prefix, including prefix itself if it is a member.
+func (c *CompactTrie) PrefixSearch(prefix string) []string {
+	n, path := c.locate(prefix)
+	if n == nil {
+		return []string{}
+	}
+	return n.appendMembers(path, []string{})
+}
+
+// Members retrieves all member strings, in order.
+func (c *CompactTrie) Members() []string {
+	return c.appendMembers(``, []string{})
+}
+
+// Size returns the number of nodes in the trie, not counting the root.
+func (c *CompactTrie) Size() (sz int) {
+	sz = len(c.edges)
+	for _, e := range c.edges {
+		sz += e.node.Size()
+	}
+	return
+}
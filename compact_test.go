@@ -0,0 +1,79 @@
+/*
+ * compact_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompact(t *testing.T) {
+	trie := NewTrie()
+	keys := []string{
+		`/usr/share/doc/readme`, `/usr/share/doc/license`, `/usr/share/man`,
+		`/usr/bin/go`, `/usr/bin/gofmt`, `/usr`, `/etc/hosts`, `/etc/ñandú`,
+	}
+	for i, k := range keys {
+		trie.AddValue(k, i)
+	}
+	c := trie.Compact()
+
+	if !reflect.DeepEqual(c.Members(), trie.Members()) {
+		t.Errorf("expected members %v but found %v", trie.Members(), c.Members())
+	}
+	if c.Size() >= trie.Size() {
+		t.Errorf("expected fewer nodes than the original %d, found %d", trie.Size(), c.Size())
+	}
+
+	queries := append([]string{
+		``, `/`, `/usr/`, `/usr/share/d`, `/usr/bin/go`, `/usr/bin/gof`, `/etc/ñ`,
+		`/etc/ñandú!`, `/usr/share/doc/readmex`, `/var`, `/usr/shore`,
+	}, keys...)
+	for _, q := range queries {
+		if c.Contains(q) != trie.Contains(q) {
+			t.Errorf("'%s': expected Contains %v", q, trie.Contains(q))
+		}
+		v, ok := c.GetValue(q)
+		ev, eok := trie.GetValue(q)
+		if v != ev || ok != eok {
+			t.Errorf("'%s': expected value (%v, %v) but found (%v, %v)", q, ev, eok, v, ok)
+		}
+		if found, expected := c.PrefixSearch(q), trie.PrefixSearch(q); !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected prefix search %v but found %v", q, expected, found)
+		}
+	}
+}
@@ -0,0 +1,479 @@
+/*
+ * encoding.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// WriteValues writes all member strings and their values to w, using enc to
+// encode each value.  The output can be read back using ReadValues.
+func (p *Trie) WriteValues(w io.Writer, enc func(v interface{}) ([]byte, error)) error {
+	buf := binary.AppendUvarint(nil, uint64(p.Count()))
+	if _, err := w.Write(buf); err != nil {
+		return err
+	}
+
+	var err error
+	p.eachLeaf(``, func(key string, leaf *Trie) bool {
+		var data []byte
+		data, err = enc(leaf.value)
+		if err != nil {
+			return false
+		}
+
+		// each entry is a length-prefixed key followed by a length-prefixed value
+		buf = binary.AppendUvarint(buf[:0], uint64(len(key)))
+		buf = append(buf, key...)
+		buf = binary.AppendUvarint(buf, uint64(len(data)))
+		buf = append(buf, data...)
+		_, err = w.Write(buf)
+		return err == nil
+	})
+
+	return err
+}
+
+// Internal function: reads a length-prefixed byte string.
+func readBytes(r *bufio.Reader) ([]byte, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if n > math.MaxInt64 {
+		return nil, fmt.Errorf("trie: length %d is out of range", n)
+	}
+
+	// the length can't be trusted, so the buffer grows only as bytes arrive
+	var b bytes.Buffer
+	if _, err = io.CopyN(&b, r, int64(n)); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// ReadValues reads member strings and their values, as written by
+// WriteValues, from r and adds them to the trie, using dec to decode each
+// value.
+func (p *Trie) ReadValues(r io.Reader, dec func([]byte) (interface{}, error)) error {
+	br := bufio.NewReader(r)
+	count, err := binary.ReadUvarint(br)
+	if err != nil {
+		return err
+	}
+
+	for i := uint64(0); i < count; i++ {
+		key, err := readBytes(br)
+		if err != nil {
+			return fmt.Errorf("trie: failed to read key %d of %d: %w", i+1, count, err)
+		}
+		data, err := readBytes(br)
+		if err != nil {
+			return fmt.Errorf("trie: failed to read value for '%s': %w", key, err)
+		}
+
+		v, err := dec(data)
+		if err != nil {
+			return err
+		}
+		p.AddValue(string(key), v)
+	}
+
+	return nil
+}
+
+func init() {
+	// the value types used by this package itself
+	gob.Register([]int32{})
+	gob.Register([]int{})
+}
+
+// a single node, as encoded by GobEncode
+type gobNode struct {
+	Rune     rune
+	Children int
+	Leaf     bool
+	Seq      uint64
+	Refs     int
+	MaxValue int
+	Value    interface{}
+}
+
+// a whole trie, as encoded by GobEncode
+type gobTrie struct {
+	Nodes      []gobNode // the nodes in depth-first order, children in rune order.
+	LastSeq    uint64
+	MaxDepth   int
+	Exceptions map[string][]int
+	Fold       bool
+}
+
+// Internal function used by GobEncode()
+func (p *Trie) appendGobNodes(r rune, nodes []gobNode) []gobNode {
+	nodes = append(nodes, gobNode{r, len(p.children), p.leaf, p.seq, p.refs, p.maxValue, p.value})
+	for _, cr := range p.sortedRunes() {
+		nodes = p.children[cr].appendGobNodes(cr, nodes)
+	}
+	return nodes
+}
+
+// GobEncode implements gob.GobEncoder, encoding the whole structure of the
+// trie, including any values.  Values of types other than those used by this
+// package must be registered with gob.Register.
+func (p *Trie) GobEncode() ([]byte, error) {
+	g := gobTrie{p.appendGobNodes(0, nil), p.lastSeq, p.MaxDepth, p.exceptions, p.fold}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&g); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// a node whose children are still to be decoded
+type pendingNode struct {
+	node      *Trie
+	remaining uint64 // the number of its children still to be decoded.
+}
+
+// Internal function used by GobDecode(): rebuilds this node and its descendants from nodes,
+// using an explicit stack for the same reason as readBinary().
+func (p *Trie) decodeGobNodes(nodes []gobNode) error {
+	if len(nodes) == 0 {
+		return errors.New("trie: truncated gob data")
+	}
+
+	stack := []pendingNode{{p, p.setGobNode(nodes[0])}}
+	nodes = nodes[1:]
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.remaining == 0 {
+			// all of the node's children have been decoded
+			top.node.resize()
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		top.remaining--
+
+		if len(nodes) == 0 {
+			return errors.New("trie: truncated gob data")
+		}
+		child := newNode()
+		top.node.children[nodes[0].Rune] = child
+		stack = append(stack, pendingNode{child, child.setGobNode(nodes[0])})
+		nodes = nodes[1:]
+	}
+
+	return nil
+}
+
+// Internal function used by decodeGobNodes(): sets this node from n, returning the number
+// of its children.
+func (p *Trie) setGobNode(n gobNode) uint64 {
+	p.leaf, p.seq, p.refs, p.maxValue, p.value = n.Leaf, n.Seq, n.Refs, n.MaxValue, n.Value
+	p.children = make(map[rune]*Trie)
+	return uint64(max(n.Children, 0))
+}
+
+// GobDecode implements gob.GobDecoder, replacing the contents of the trie with
+// those encoded by GobEncode.
+func (p *Trie) GobDecode(data []byte) error {
+	var g gobTrie
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&g); err != nil {
+		return err
+	}
+
+	if err := p.decodeGobNodes(g.Nodes); err != nil {
+		return err
+	}
+	if p.rootConfig == nil {
+		p.rootConfig = new(rootConfig)
+	}
+	p.lastSeq, p.MaxDepth, p.exceptions, p.fold = g.LastSeq, g.MaxDepth, g.Exceptions, g.Fold
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, encoding the trie as an object
+// mapping each member string to its value.  Members without a value map to
+// null.
+func (p *Trie) MarshalJSON() ([]byte, error) {
+	m := make(map[string]interface{})
+	p.eachLeaf(``, func(key string, leaf *Trie) bool {
+		m[key] = leaf.value
+		return true
+	})
+	return json.Marshal(m)
+}
+
+// UnmarshalJSON implements json.Unmarshaler, replacing the contents of the
+// trie with the members and values of a JSON object as produced by
+// MarshalJSON.  Values are decoded as by json.Unmarshal into an interface{},
+// so numbers become float64 and arrays []interface{}.
+func (p *Trie) UnmarshalJSON(data []byte) error {
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		return err
+	}
+
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	p.Clear()
+	for _, key := range keys {
+		p.AddValue(key, m[key])
+	}
+	return nil
+}
+
+// MarshalText implements encoding.TextMarshaler, encoding the trie as its
+// member strings in order, one per line.  Values are not included, and
+// members containing a newline can't be read back.
+func (p *Trie) MarshalText() ([]byte, error) {
+	return []byte(strings.Join(p.Members(), "\n")), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler, replacing the contents of
+// the trie with the lines of text, as produced by MarshalText.  Empty lines,
+// including any following a trailing newline, are ignored.
+func (p *Trie) UnmarshalText(text []byte) error {
+	p.Clear()
+	for _, line := range strings.Split(string(text), "\n") {
+		p.AddString(line)
+	}
+	return nil
+}
+
+// value type tags used by WriteTo and ReadTrie
+const (
+	binaryNil   = 0
+	binaryInt32 = 1
+)
+
+// Internal function used by WriteTo(): appends the encoding of this node and its descendants
+// to buf.
+func (p *Trie) appendBinary(r rune, buf []byte) ([]byte, error) {
+	buf = binary.AppendUvarint(buf, uint64(r))
+	buf = binary.AppendUvarint(buf, uint64(len(p.children)))
+	if p.leaf {
+		buf = append(buf, 1)
+	} else {
+		buf = append(buf, 0)
+	}
+
+	switch v := p.value.(type) {
+	case nil:
+		buf = append(buf, binaryNil)
+	case []int32:
+		buf = append(buf, binaryInt32)
+		buf = binary.AppendUvarint(buf, uint64(len(v)))
+		for _, i := range v {
+			buf = binary.AppendVarint(buf, int64(i))
+		}
+	default:
+		return nil, fmt.Errorf("trie: unsupported value type %T in binary encoding", p.value)
+	}
+
+	var err error
+	for _, cr := range p.sortedRunes() {
+		if buf, err = p.children[cr].appendBinary(cr, buf); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+// WriteTo writes a compact binary encoding of the trie to w, returning the
+// number of bytes written.  Only nil and []int32 values, as used for
+// hyphenation patterns, are supported; any other value causes an error, and
+// nothing is written.  The trie can be read back using ReadTrie.
+func (p *Trie) WriteTo(w io.Writer) (int64, error) {
+	buf, err := p.appendBinary(0, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := w.Write(buf)
+	return int64(n), err
+}
+
+// Internal function used by ReadTrie(): reads this node and its descendants.  The depth of
+// the trie comes from the input, so they are read with an explicit stack rather than by
+// recursion, which a deep enough input could use to overflow the goroutine's stack.
+func (p *Trie) readBinary(r io.ByteReader) error {
+	numChildren, err := p.readBinaryNode(p, r)
+	if err != nil {
+		return err
+	}
+
+	stack := []pendingNode{{p, numChildren}}
+	for len(stack) != 0 {
+		top := &stack[len(stack)-1]
+		if top.remaining == 0 {
+			// all of the node's children have been read
+			top.node.resize()
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		top.remaining--
+
+		cr, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		child := newNode()
+		numChildren, err := child.readBinaryNode(p, r)
+		if err != nil {
+			return err
+		}
+		top.node.children[rune(cr)] = child
+		stack = append(stack, pendingNode{child, numChildren})
+	}
+	return nil
+}
+
+// Internal function used by readBinary(): reads the leaf flag and value of this node, below
+// root, returning the number of its children which follow.  The nodes are encoded in order,
+// so the members are numbered in order as they are read.
+func (p *Trie) readBinaryNode(root *Trie, r io.ByteReader) (uint64, error) {
+	numChildren, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, err
+	}
+	leaf, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if leaf != 0 {
+		root.setLeaf(p)
+	}
+
+	tag, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	switch tag {
+	case binaryNil:
+	case binaryInt32:
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, err
+		}
+		// the count can't be trusted, so the slice grows only as values are read
+		v := []int32{}
+		for i := uint64(0); i < n; i++ {
+			x, err := binary.ReadVarint(r)
+			if err != nil {
+				return 0, err
+			}
+			v = append(v, int32(x))
+		}
+		p.value = v
+	default:
+		return 0, fmt.Errorf("trie: unknown value type tag %d in binary encoding", tag)
+	}
+	return numChildren, nil
+}
+
+// ReadTrie reads a trie in the binary encoding written by WriteTo.
+func ReadTrie(r io.Reader) (*Trie, error) {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+
+	// the root's rune is meaningless
+	if _, err := binary.ReadUvarint(br); err != nil {
+		return nil, err
+	}
+
+	t := NewTrie()
+	if err := t.readBinary(br); err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return nil, err
+	}
+	return t, nil
+}
+
+// Internal function used by WriteDOT(): appends the vertices and edges of this node and its
+// descendants to buf, numbering them in depth-first order from *id.
+func (p *Trie) appendDOT(buf *bytes.Buffer, id *int) {
+	self := *id
+	shape := `circle`
+	if p.leaf {
+		shape = `doublecircle`
+	}
+	fmt.Fprintf(buf, "\tn%d [shape=%s, label=\"\"];\n", self, shape)
+
+	for _, r := range p.sortedRunes() {
+		*id++
+		fmt.Fprintf(buf, "\tn%d -> n%d [label=%q];\n", self, *id, string(r))
+		p.children[r].appendDOT(buf, id)
+	}
+}
+
+// WriteDOT writes the structure of the trie to w as a Graphviz digraph, which
+// can be rendered with a command such as 'dot -Tpng'.  Each edge is labelled
+// with its rune, and the nodes ending members are drawn as double circles.
+func (p *Trie) WriteDOT(w io.Writer) error {
+	var buf bytes.Buffer
+	buf.WriteString("digraph trie {\n")
+	id := 0
+	p.appendDOT(&buf, &id)
+	buf.WriteString("}\n")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,356 @@
+/*
+ * encoding_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func encodeInt32s(v interface{}) ([]byte, error) {
+	vals, ok := v.([]int32)
+	if !ok {
+		return nil, fmt.Errorf("Unexpected value type %T", v)
+	}
+	buf := []byte{}
+	for _, val := range vals {
+		buf = binary.AppendVarint(buf, int64(val))
+	}
+	return buf, nil
+}
+
+func decodeInt32s(b []byte) (interface{}, error) {
+	vals := []int32{}
+	for len(b) > 0 {
+		v, n := binary.Varint(b)
+		if n <= 0 {
+			return nil, fmt.Errorf("Bad varint in %v", b)
+		}
+		vals = append(vals, int32(v))
+		b = b[n:]
+	}
+	return vals, nil
+}
+
+func TestValuesRoundTrip(t *testing.T) {
+	trie := NewTrie()
+	trie.AddPatternString(`hy3phe2n5a4t2io2n`)
+	trie.AddPatternString(`5emnix`)
+	trie.AddPatternString(`.ach4`)
+	trie.AddValue(`négatif`, []int32{-1, 0, 1})
+
+	var buf bytes.Buffer
+	if err := trie.WriteValues(&buf, encodeInt32s); err != nil {
+		t.Fatalf("Failed to write values: %s", err)
+	}
+
+	decoded := NewTrie()
+	if err := decoded.ReadValues(&buf, decodeInt32s); err != nil {
+		t.Fatalf("Failed to read values: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Members(), trie.Members()) {
+		t.Errorf("expected members %v, found %v", trie.Members(), decoded.Members())
+	}
+	for _, key := range trie.Members() {
+		expected, _ := trie.GetValue(key)
+		found, _ := decoded.GetValue(key)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("expected value %v for '%s', found %v", expected, key, found)
+		}
+	}
+
+	// corrupt lengths are reported rather than allocated
+	for _, input := range [][]byte{
+		{1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01},
+		{1, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		{1, 5, 'a'},
+	} {
+		if err := NewTrie().ReadValues(bytes.NewReader(input), decodeInt32s); err == nil {
+			t.Errorf("reading %v should fail", input)
+		}
+	}
+	err := NewTrie().ReadValues(bytes.NewReader([]byte{1, 5, 'a'}), decodeInt32s)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("expected a wrapped io.ErrUnexpectedEOF, found %v", err)
+	}
+
+	// encoder errors are passed back to the caller
+	trie.AddString(`novalue`)
+	if err := trie.WriteValues(&buf, encodeInt32s); err == nil {
+		t.Error("writing a value the encoder can't handle should fail")
+	}
+}
+
+func TestGobRoundTrip(t *testing.T) {
+	trie := NewTrie()
+	trie.AddPatternString(`hy3phe2n5a4t2io2n`)
+	trie.AddPatternString(`5emnix`)
+	trie.AddString(`hyph`)
+	trie.AddValue(`count`, 42)
+	trie.AddValue(`naïve`, `unicode`)
+	trie.SetNodeValue(`co`, `internal`)
+	trie.AddExceptionString(`as-so-ciate`)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(trie); err != nil {
+		t.Fatalf("Failed to encode trie: %s", err)
+	}
+
+	decoded := NewTrie()
+	if err := gob.NewDecoder(&buf).Decode(decoded); err != nil {
+		t.Fatalf("Failed to decode trie: %s", err)
+	}
+
+	if !reflect.DeepEqual(decoded.Members(), trie.Members()) {
+		t.Errorf("expected members %v, found %v", trie.Members(), decoded.Members())
+	}
+	if decoded.Size() != trie.Size() {
+		t.Errorf("expected %d nodes, found %d", trie.Size(), decoded.Size())
+	}
+	for _, key := range append(trie.Members(), `hyphen`, `co`) {
+		expected, expectedOK := trie.GetValue(key)
+		found, ok := decoded.GetValue(key)
+		if ok != expectedOK || !reflect.DeepEqual(found, expected) {
+			t.Errorf("expected value %v (%v) for '%s', found %v (%v)", expected, expectedOK, key, found, ok)
+		}
+		if decoded.Contains(key) != trie.Contains(key) {
+			t.Errorf("expected Contains('%s') to be %v", key, trie.Contains(key))
+		}
+	}
+	if v, _ := decoded.GetNodeValue(`co`); v != `internal` {
+		t.Errorf("expected the internal node value to survive, found %v", v)
+	}
+	if !reflect.DeepEqual(decoded.MembersByInsertionOrder(), trie.MembersByInsertionOrder()) {
+		t.Errorf("expected insertion order %v, found %v", trie.MembersByInsertionOrder(), decoded.MembersByInsertionOrder())
+	}
+	if !reflect.DeepEqual(decoded.Hyphenate(`associate`), []string{`as`, `so`, `ciate`}) {
+		t.Errorf("expected exceptions to survive, found %v", decoded.Hyphenate(`associate`))
+	}
+
+	// a deep trie is decoded without recursing once per level, and truncated data is reported
+	nodes := make([]gobNode, 100001)
+	for i := range nodes {
+		nodes[i] = gobNode{Rune: 'a', Children: 1}
+	}
+	nodes[len(nodes)-1] = gobNode{Rune: 'a', Leaf: true, Refs: 1}
+	for _, test := range []struct {
+		nodes []gobNode
+		ok    bool
+	}{{nodes, true}, {nodes[:len(nodes)-1], false}} {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&gobTrie{Nodes: test.nodes}); err != nil {
+			t.Fatalf("Failed to encode gob nodes: %s", err)
+		}
+		deep := NewTrie()
+		err := deep.GobDecode(buf.Bytes())
+		if test.ok && (err != nil || deep.Height() != 100000 || deep.Size() != 100000) {
+			t.Errorf("expected a trie 100000 deep, found error %v", err)
+		}
+		if !test.ok && err == nil {
+			t.Error("expected truncated gob data to fail")
+		}
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`plain`)
+	trie.AddValue(`naïve`, `café`)
+	trie.AddValue(`日本語`, 3.5)
+	trie.AddValue(`list`, []interface{}{1.0, `two`})
+	trie.SetNodeValue(`pl`, `internal nodes are not members`)
+
+	data, err := json.Marshal(trie)
+	if err != nil {
+		t.Fatalf("Failed to marshal trie: %s", err)
+	}
+
+	expected := `{"list":[1,"two"],"naïve":"café","plain":null,"日本語":3.5}`
+	if string(data) != expected {
+		t.Errorf("expected JSON %s, found %s", expected, data)
+	}
+
+	var decoded *Trie
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to unmarshal trie: %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Members(), trie.Members()) {
+		t.Errorf("expected members %v, found %v", trie.Members(), decoded.Members())
+	}
+	for _, key := range trie.Members() {
+		expected, _ := trie.GetValue(key)
+		found, _ := decoded.GetValue(key)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("expected value %v for '%s', found %v", expected, key, found)
+		}
+	}
+
+	if err := decoded.UnmarshalJSON([]byte(`[1, 2]`)); err == nil {
+		t.Error("unmarshalling a JSON array should fail")
+	}
+}
+
+func TestTextRoundTrip(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`plain`, `naïve`, `日本語`, `ñandú`, `ñ`} {
+		trie.AddString(s)
+	}
+
+	data, err := trie.MarshalText()
+	if err != nil {
+		t.Fatalf("Failed to marshal trie: %s", err)
+	}
+	expected := "naïve\nplain\nñ\nñandú\n日本語"
+	if string(data) != expected {
+		t.Errorf("expected text %q, found %q", expected, data)
+	}
+
+	decoded := NewTrie()
+	decoded.AddString(`replaced`)
+	if err := decoded.UnmarshalText(data); err != nil {
+		t.Fatalf("Failed to unmarshal trie: %s", err)
+	}
+	if !decoded.Equal(trie) {
+		t.Errorf("expected members %v, found %v", trie.Members(), decoded.Members())
+	}
+
+	// blank lines and a trailing newline add nothing
+	if err := decoded.UnmarshalText([]byte("one\n\ntwo\n")); err != nil {
+		t.Fatalf("Failed to unmarshal trie: %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Members(), []string{`one`, `two`}) || decoded.Size() != 6 {
+		t.Errorf("expected members [one two], found %v", decoded.Members())
+	}
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	trie := NewTrie()
+	trie.AddPatternString(`hy3phe2n5a4t2io2n`)
+	trie.AddPatternString(`5emnix`)
+	trie.AddPatternString(`.ach4`)
+	trie.AddPatternString(`ñ1o`)
+	trie.AddString(`plain`)
+
+	var buf bytes.Buffer
+	n, err := trie.WriteTo(&buf)
+	if err != nil {
+		t.Fatalf("Failed to write trie: %s", err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("WriteTo reported %d bytes, but wrote %d", n, buf.Len())
+	}
+
+	decoded, err := ReadTrie(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read trie: %s", err)
+	}
+	if !reflect.DeepEqual(decoded.Members(), trie.Members()) {
+		t.Errorf("expected members %v, found %v", trie.Members(), decoded.Members())
+	}
+	if decoded.Size() != trie.Size() {
+		t.Errorf("expected %d nodes, found %d", trie.Size(), decoded.Size())
+	}
+	for _, key := range trie.Members() {
+		expected, _ := trie.GetValue(key)
+		found, _ := decoded.GetValue(key)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("expected value %v for '%s', found %v", expected, key, found)
+		}
+	}
+
+	// truncated input
+	buf.Reset()
+	trie.WriteTo(&buf)
+	if _, err := ReadTrie(bytes.NewReader(buf.Bytes()[:buf.Len()/2])); err == nil {
+		t.Error("reading a truncated trie should fail")
+	}
+
+	// a deep trie is read without recursing once per level
+	deep := NewTrie()
+	deep.AddString(strings.Repeat(`a`, 100000))
+	buf.Reset()
+	deep.WriteTo(&buf)
+	if decoded, err := ReadTrie(&buf); err != nil || decoded.Height() != 100000 || decoded.Size() != 100000 {
+		t.Errorf("expected a trie 100000 deep, found error %v", err)
+	}
+
+	// a corrupt value count is reported rather than allocated
+	corrupt := []byte{0, 0, 1, binaryInt32, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01}
+	if _, err := ReadTrie(bytes.NewReader(corrupt)); err == nil {
+		t.Error("reading a corrupt value count should fail")
+	}
+
+	// unsupported values
+	trie.AddValue(`other`, `string`)
+	buf.Reset()
+	if n, err := trie.WriteTo(&buf); err == nil || n != 0 || buf.Len() != 0 {
+		t.Errorf("writing a string value should fail without writing, got %d bytes and %v", n, err)
+	}
+}
+
+func TestWriteDOT(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`añ`)
+
+	var buf bytes.Buffer
+	if err := trie.WriteDOT(&buf); err != nil {
+		t.Fatalf("Failed to write DOT: %s", err)
+	}
+
+	expected := "digraph trie {\n" +
+		"\tn0 [shape=circle, label=\"\"];\n" +
+		"\tn0 -> n1 [label=\"a\"];\n" +
+		"\tn1 [shape=circle, label=\"\"];\n" +
+		"\tn1 -> n2 [label=\"b\"];\n" +
+		"\tn2 [shape=doublecircle, label=\"\"];\n" +
+		"\tn1 -> n3 [label=\"ñ\"];\n" +
+		"\tn3 [shape=doublecircle, label=\"\"];\n" +
+		"}\n"
+	if buf.String() != expected {
+		t.Errorf("expected DOT output:\n%s\nbut found:\n%s", expected, buf.String())
+	}
+}
@@ -39,61 +39,282 @@
 package trie
 
 import (
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
 // AddPatternString is a specialized function for TeX-style hyphenation
-// patterns.  Accepts strings of the form '.hy2p'.
+// patterns.  Accepts strings of the form '.hy2p'.  The digits are removed to
+// give the member string, whose value is a []int32 holding the number
+// following each rune, or zero where there is none.  A pattern beginning
+// with a digit, such as '5emnix', has that digit as an extra first value.
 func (p *Trie) AddPatternString(s string) {
-	v := []rune{}
-
-	// precompute the Unicode rune for the character '0'
-	r0, _ := utf8.DecodeRune([]byte{'0'})
-
-	strLen := len(s)
+	s = p.key(s)
+	pure := make([]rune, 0, len(s))
+	v := []int32{}
 
 	// Using the range keyword will give us each Unicode rune.
 	for pos, r := range s {
 		if unicode.IsDigit(r) {
 			if pos == 0 {
 				// This is a prefix number
-				v = append(v, (r - r0))
+				v = append(v, r-'0')
+			} else {
+				// this is the hyphenation value for the previous character
+				v[len(v)-1] = r - '0'
 			}
-
-			// this is a number referring to the previous character, and has
-			// already been handled
 			continue
 		}
 
-		if pos < strLen-1 {
-			// look ahead to see if it's followed by a number
-			next := rune(s[pos+1])
-			if unicode.IsDigit(next) {
-				// next char is the hyphenation value for this char
-				v = append(v, (next - r0))
-			} else {
-				// hyphenation for this char is an implied zero
-				v = append(v, 0)
+		// hyphenation for this char is an implied zero unless a number follows
+		pure = append(pure, r)
+		v = append(v, 0)
+	}
+	if len(pure) == 0 {
+		return // empty strings can't be added
+	}
+
+	leaf, err := p.addString(string(pure), p.MaxDepth)
+	if err != nil {
+		return
+	}
+	old := leaf.memberValue()
+	p.setLeaf(leaf)
+	leaf.value = v
+	p.valueChanged(string(pure), old, v)
+}
+
+// ExportPatterns returns, in order, the TeX-style hyphenation patterns stored
+// in the trie, in the form accepted by AddPatternString.  Zero values are
+// omitted, as they are in TeX.  Members whose values aren't []int32 of the
+// layout AddPatternString produces are skipped.
+func (p *Trie) ExportPatterns() []string {
+	patterns := []string{}
+	p.eachLeaf(``, func(key string, leaf *Trie) bool {
+		v, ok := leaf.value.([]int32)
+		if !ok {
+			return true
+		}
+
+		// a value for each rune, with perhaps one for the start of the pattern
+		offset := len(v) - utf8.RuneCountInString(key)
+		if offset != 0 && offset != 1 {
+			return true
+		}
+
+		var b strings.Builder
+		if offset == 1 && v[0] != 0 {
+			b.WriteString(strconv.Itoa(int(v[0])))
+		}
+		i := offset
+		for _, r := range key {
+			b.WriteRune(r)
+			if v[i] != 0 {
+				b.WriteString(strconv.Itoa(int(v[i])))
+			}
+			i++
+		}
+		patterns = append(patterns, b.String())
+		return true
+	})
+
+	sort.Strings(patterns)
+	return patterns
+}
+
+// AddExceptionString is a specialized function for TeX-style hyphenation
+// exceptions.  Accepts strings of the form 'as-so-ciate', and records the
+// explicit hyphenation points of the word, which take precedence over any
+// points computed from the trie's patterns.
+func (p *Trie) AddExceptionString(s string) {
+	points := []int{}
+	pos := 0
+
+	for _, r := range s {
+		if r == '-' {
+			if pos > 0 {
+				points = append(points, pos)
 			}
-		} else {
-			// last character gets an implied zero
-			v = append(v, 0)
+			continue
 		}
+		pos++
 	}
 
-	pure := strings.Map(func(r rune) rune {
-		if unicode.IsDigit(r) {
+	word := strings.Map(func(r rune) rune {
+		if r == '-' {
 			return -1
 		}
-		return r
+		return unicode.ToLower(r)
 	},
 		s)
-	leaf := p.addRunes(strings.NewReader(pure))
-	if leaf == nil {
+	if len(word) == 0 {
 		return
 	}
 
-	leaf.value = v
+	if p.exceptions == nil {
+		p.exceptions = make(map[string][]int)
+	}
+	p.exceptions[word] = points
+}
+
+// Internal function: computes the rune offsets within word at which it may be
+// hyphenated, using exceptions if available and patterns otherwise.
+func (p *Trie) hyphenationPoints(word string) []int {
+	lower := strings.Map(unicode.ToLower, word)
+	if points, ok := p.exceptions[lower]; ok {
+		return points
+	}
+
+	// patterns may be anchored to the start or end of the word with '.'
+	padded := `.` + lower + `.`
+	merged := mergePatterns(padded, func(s string, add func(gap int, vals []int32)) {
+		strs, values := p.AllSubstringsWithValues(s)
+		for j, value := range values {
+			// a value for the gap following each rune, unless there is an extra first value
+			// for the gap before them
+			if val, ok := value.([]int32); ok {
+				add(utf8.RuneCountInString(strs[j])+1-len(val), val)
+			}
+		}
+	})
+
+	// odd values between two runes of the word are hyphenation points; the gap before
+	// rune k of the word follows padded rune k
+	points := []int{}
+	for k := 1; k < len(merged)-3; k++ {
+		if merged[k+1]%2 == 1 {
+			points = append(points, k)
+		}
+	}
+
+	return points
+}
+
+// Internal function used by hyphenationPoints() and PatternTrie.Hyphenate(): merges the
+// values of the patterns matching padded, keeping the largest value for each gap.  The
+// result holds a value for the gap before each rune of padded, and one for the gap after
+// it.  matches is called with each suffix of padded, and calls add for every pattern
+// beginning it, with the gap, relative to the suffix, to which the first of the values
+// applies.  The patterns of a Trie only have a value for the gap before their first rune
+// when it is given, so they don't all start at the same gap.
+func mergePatterns[V int | int32](padded string, matches func(s string, add func(gap int, vals []V))) []V {
+	merged := make([]V, utf8.RuneCountInString(padded)+1)
+	i := 0
+	for pos := range padded {
+		matches(padded[pos:], func(gap int, vals []V) {
+			for k, v := range vals {
+				if g := i + gap + k; g >= 0 && g < len(merged) && v > merged[g] {
+					merged[g] = v
+				}
+			}
+		})
+		i++
+	}
+	return merged
+}
+
+// Hyphenate splits a word into the pieces between which it may be hyphenated,
+// according to the patterns and exceptions stored in the trie.  Exceptions
+// take precedence over the patterns.
+func (p *Trie) Hyphenate(word string) []string {
+	runes := []rune(word)
+	pieces := []string{}
+
+	last := 0
+	for _, pt := range p.hyphenationPoints(word) {
+		pieces = append(pieces, string(runes[last:pt]))
+		last = pt
+	}
+	pieces = append(pieces, string(runes[last:]))
+
+	return pieces
+}
+
+// AddCharValues adds a string to the trie, storing each of vals as the value
+// of the node for the corresponding rune, rather than storing them all on
+// the leaf.  vals must hold exactly one value per rune of s, as it is stored
+// in the trie.
+func (p *Trie) AddCharValues(s string, vals []int32) {
+	s = p.key(s)
+	if utf8.RuneCountInString(s) != len(vals) {
+		panic("trie: AddCharValues needs exactly one value per rune")
+	}
+	if len(s) == 0 {
+		return
+	}
+
+	leaf, _ := p.addString(s, 0)
+	p.setLeaf(leaf)
+
+	n := p
+	path := []*Trie{p}
+	i := 0
+	for _, r := range s {
+		n = n.children[r]
+		n.value = vals[i]
+		path = append(path, n)
+		i++
+	}
+
+	// an int member value replaced along the path no longer counts towards maxValue
+	if p.maxValue != math.MinInt {
+		for i := len(path) - 1; i >= 0; i-- {
+			path[i].remax()
+		}
+	}
+}
+
+// CharValues returns the values stored on each node along the path of a
+// member string, as added by AddCharValues.  Nodes without an int32 value
+// are given a value of zero.  The bool is false if s is not a member.
+func (p *Trie) CharValues(s string) ([]int32, bool) {
+	s = p.key(s)
+	if len(s) == 0 || p.includes(strings.NewReader(s)) == nil {
+		return nil, false
+	}
+
+	vals := make([]int32, 0, utf8.RuneCountInString(s))
+	for _, r := range s {
+		p = p.children[r]
+		v, _ := p.value.(int32)
+		vals = append(vals, v)
+	}
+
+	return vals, true
+}
+
+// MergeMax merges the per-character values of other, as stored by
+// AddCharValues, into the trie.  Each node keeps the larger of its own and
+// the incoming int32 value, and paths present only in other are added.
+func (p *Trie) MergeMax(other *Trie) {
+	p.mergeMax(p, other)
+}
+
+// Internal function used by MergeMax(): merges other into this node, where root is the
+// root of the trie containing this node.
+func (p *Trie) mergeMax(root, other *Trie) {
+	if other.leaf {
+		root.setLeaf(p)
+	}
+
+	if incoming, ok := other.value.(int32); ok {
+		if existing, ok := p.value.(int32); !ok || incoming > existing {
+			p.value = incoming
+		}
+	}
+
+	for r, otherChild := range other.children {
+		child, ok := p.children[r]
+		if !ok {
+			child = newNode()
+			p.children[r] = child
+		}
+		child.mergeMax(root, otherChild)
+	}
+	p.resize()
+	p.remax()
 }
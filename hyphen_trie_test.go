@@ -0,0 +1,222 @@
+/*
+ * hyphen_trie_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// the patterns from Liang's thesis which apply to the word 'hyphenation'
+var hyphenationPatterns = []string{
+	`hy3ph`, `he2n`, `hena4`, `hen5at`, `1na`, `n2at`, `1tio`, `2io`, `o2n`,
+}
+
+func hyphenationTrie() *Trie {
+	trie := NewTrie()
+	for _, pat := range hyphenationPatterns {
+		trie.AddPatternString(pat)
+	}
+	return trie
+}
+
+func TestHyphenateExceptions(t *testing.T) {
+	trie := hyphenationTrie()
+
+	expected := []string{`hy`, `phen`, `ation`}
+	found := trie.Hyphenate(`hyphenation`)
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("pattern hyphenation: expected %v but found %v", expected, found)
+	}
+
+	trie.AddExceptionString(`hy-phen-a-tion`)
+	expected = []string{`hy`, `phen`, `a`, `tion`}
+	found = trie.Hyphenate(`hyphenation`)
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("exception hyphenation: expected %v but found %v", expected, found)
+	}
+
+	// exceptions are matched without regard to case
+	expected = []string{`Hy`, `phen`, `a`, `tion`}
+	found = trie.Hyphenate(`Hyphenation`)
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("exception hyphenation: expected %v but found %v", expected, found)
+	}
+
+	// an exception with no hyphens prevents hyphenation altogether
+	trie.AddExceptionString(`hyphenation`)
+	expected = []string{`hyphenation`}
+	found = trie.Hyphenate(`hyphenation`)
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("exception hyphenation: expected %v but found %v", expected, found)
+	}
+
+	// exceptions are not members of the trie
+	if trie.Contains(`hyphenation`) {
+		t.Error("trie should not contain the exception word 'hyphenation'")
+	}
+}
+
+func TestCharValues(t *testing.T) {
+	trie := NewTrie()
+
+	str := `hyphénation`
+	hyp := []int32{0, 3, 0, 0, 2, 5, 4, 2, 0, 2, 0}
+	trie.AddCharValues(str, hyp)
+
+	if trie.Size() != len(hyp) {
+		t.Errorf("trie should have %d nodes, one per rune, but has %d", len(hyp), trie.Size())
+	}
+
+	found, ok := trie.CharValues(str)
+	if !ok || !reflect.DeepEqual(found, hyp) {
+		t.Errorf("expected values %v for '%s', found %v (%v)", hyp, str, found, ok)
+	}
+
+	// a prefix shares the values of its nodes, but isn't a member
+	if _, ok := trie.CharValues(`hyph`); ok {
+		t.Error("'hyph' should not be a member of the trie")
+	}
+	trie.AddCharValues(`hyph`, []int32{1, 1, 1, 1})
+	found, _ = trie.CharValues(str)
+	expected := []int32{1, 1, 1, 1, 2, 5, 4, 2, 0, 2, 0}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected values %v for '%s', found %v", expected, str, found)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("AddCharValues should panic when given the wrong number of values")
+		}
+	}()
+	trie.AddCharValues(`abc`, []int32{1, 2})
+}
+
+func TestMergeMax(t *testing.T) {
+	a := NewTrie()
+	a.AddCharValues(`hyph`, []int32{0, 3, 0, 0})
+	a.AddCharValues(`hena`, []int32{0, 0, 0, 4})
+
+	b := NewTrie()
+	b.AddCharValues(`hyph`, []int32{1, 2, 0, 5})
+	b.AddCharValues(`hen`, []int32{1, 2, 0})
+	b.AddCharValues(`tion`, []int32{1, 0, 0, 0})
+
+	a.MergeMax(b)
+
+	expected := map[string][]int32{
+		`hyph`: {1, 3, 0, 5},
+		`hena`: {1, 2, 0, 4},
+		`hen`:  {1, 2, 0},
+		`tion`: {1, 0, 0, 0},
+	}
+	for s, e := range expected {
+		if found, ok := a.CharValues(s); !ok || !reflect.DeepEqual(found, e) {
+			t.Errorf("expected merged values %v for '%s', found %v (%v)", e, s, found, ok)
+		}
+	}
+	if len(a.Members()) != len(expected) {
+		t.Errorf("expected %d members after merging, found %v", len(expected), a.Members())
+	}
+
+	// the source is untouched
+	if found, _ := b.CharValues(`hyph`); !reflect.DeepEqual(found, []int32{1, 2, 0, 5}) {
+		t.Errorf("merging should not modify the source trie, found %v", found)
+	}
+}
+
+func TestAddPatternString(t *testing.T) {
+	tests := []struct {
+		pattern string
+		word    string
+		values  []int32
+	}{
+		{`hy3phe2n5a4t2io2n`, `hyphenation`, []int32{0, 3, 0, 0, 2, 5, 4, 2, 0, 2, 0}},
+		{`5emnix`, `emnix`, []int32{5, 0, 0, 0, 0, 0}},
+		{`.ach4`, `.ach`, []int32{0, 0, 0, 4}},
+		{`ñ1o`, `ño`, []int32{1, 0}},
+		{`1na`, `na`, []int32{1, 0, 0}},
+	}
+
+	for _, test := range tests {
+		trie := NewTrie()
+		trie.AddPatternString(test.pattern)
+		if !reflect.DeepEqual(trie.Members(), []string{test.word}) {
+			t.Errorf("'%s': expected the member '%s' but found %v", test.pattern, test.word, trie.Members())
+		}
+		v, _ := trie.GetValue(test.word)
+		if !reflect.DeepEqual(v, test.values) {
+			t.Errorf("'%s': expected values %v but found %v", test.pattern, test.values, v)
+		}
+	}
+
+	// patterns without any runes to match are ignored
+	trie := NewTrie()
+	for _, pattern := range []string{``, `5`, `12`} {
+		trie.AddPatternString(pattern)
+	}
+	if trie.Count() != 0 || trie.Size() != 0 {
+		t.Errorf("expected empty patterns to be ignored, found members %q", trie.Members())
+	}
+}
+
+func TestExportPatterns(t *testing.T) {
+	patterns := append([]string{`hy3phe2n5a4t2io2n`, `5emnix`, `.ach4`, `ñ1o`}, hyphenationPatterns...)
+	trie := NewTrie()
+	for _, pat := range patterns {
+		trie.AddPatternString(pat)
+	}
+	trie.AddValue(`notapattern`, 1)
+
+	exported := trie.ExportPatterns()
+	expected := append([]string{}, patterns...)
+	sort.Strings(expected)
+	if !reflect.DeepEqual(exported, expected) {
+		t.Errorf("expected %v but found %v", expected, exported)
+	}
+
+	// re-adding the exported patterns gives the same trie
+	trie.Remove(`notapattern`)
+	reloaded := NewTrie()
+	for _, pat := range exported {
+		reloaded.AddPatternString(pat)
+	}
+	if !reloaded.Equal(trie) {
+		t.Errorf("expected the re-added patterns to match, found %v", reloaded.ExportPatterns())
+	}
+}
@@ -0,0 +1,176 @@
+/*
+ * immutable.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// An ImmutableTrie is a persistent trie: adding or removing a string returns a
+// new version, leaving the original unchanged.  Only the nodes along the
+// modified path are copied; all other nodes are shared between versions.
+type ImmutableTrie struct {
+	leaf     bool                    // whether the node is a leaf (the end of an input string).
+	children map[rune]*ImmutableTrie // a map of sub-tries for each child rune value.
+}
+
+// NewImmutableTrie creates and returns a new, empty ImmutableTrie.
+func NewImmutableTrie() *ImmutableTrie {
+	t := new(ImmutableTrie)
+	t.children = make(map[rune]*ImmutableTrie)
+	return t
+}
+
+// Internal function: returns a copy of this node, sharing its children.
+func (t *ImmutableTrie) copyNode() *ImmutableTrie {
+	n := NewImmutableTrie()
+	n.leaf = t.leaf
+	for r, child := range t.children {
+		n.children[r] = child
+	}
+	return n
+}
+
+// Internal function used by Add()
+func (t *ImmutableTrie) add(s string) *ImmutableTrie {
+	n := t.copyNode()
+	if len(s) == 0 {
+		n.leaf = true
+		return n
+	}
+
+	r, size := utf8.DecodeRuneInString(s)
+	child, ok := t.children[r]
+	if !ok {
+		child = NewImmutableTrie()
+	}
+	n.children[r] = child.add(s[size:])
+	return n
+}
+
+// Add returns a version of the trie which also contains s.  If s is already
+// present the receiver itself is returned.
+func (t *ImmutableTrie) Add(s string) *ImmutableTrie {
+	if len(s) == 0 || t.Contains(s) {
+		return t
+	}
+	return t.add(s)
+}
+
+// Internal function used by Remove().  Returns nil if the node is empty following the
+// removal.
+func (t *ImmutableTrie) remove(s string) *ImmutableTrie {
+	n := t.copyNode()
+	if len(s) == 0 {
+		n.leaf = false
+	} else {
+		r, size := utf8.DecodeRuneInString(s)
+		if child := t.children[r].remove(s[size:]); child != nil {
+			n.children[r] = child
+		} else {
+			// the child is now empty following the removal, so prune it
+			delete(n.children, r)
+		}
+	}
+
+	if !n.leaf && len(n.children) == 0 {
+		return nil
+	}
+	return n
+}
+
+// Remove returns a version of the trie which does not contain s.  If s is not
+// present the receiver itself is returned.
+func (t *ImmutableTrie) Remove(s string) *ImmutableTrie {
+	if !t.Contains(s) {
+		return t
+	}
+	if n := t.remove(s); n != nil {
+		return n
+	}
+	return NewImmutableTrie()
+}
+
+// Contains tests for the inclusion of a particular string in the trie.
+func (t *ImmutableTrie) Contains(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+
+	for _, r := range s {
+		child, ok := t.children[r]
+		if !ok {
+			return false
+		}
+		t = child
+	}
+	return t.leaf
+}
+
+// Internal output-building function used by Members()
+func (t *ImmutableTrie) buildMembers(prefix string) []string {
+	strList := []string{}
+
+	if t.leaf {
+		strList = append(strList, prefix)
+	}
+
+	for r, child := range t.children {
+		strList = append(strList, child.buildMembers(prefix+string(r))...)
+	}
+
+	return strList
+}
+
+// Members retrieves all member strings, in order.
+func (t *ImmutableTrie) Members() []string {
+	members := t.buildMembers(``)
+	sort.Strings(members)
+	return members
+}
+
+// Size counts all the nodes of the entire trie, NOT including the root node.
+func (t *ImmutableTrie) Size() (sz int) {
+	sz = len(t.children)
+
+	for _, child := range t.children {
+		sz += child.Size()
+	}
+
+	return
+}
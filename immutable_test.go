@@ -0,0 +1,93 @@
+/*
+ * immutable_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"testing"
+)
+
+// counts the nodes of b which are shared with a
+func sharedNodes(a, b *ImmutableTrie) int {
+	if a == b {
+		return b.Size() + 1
+	}
+
+	n := 0
+	for r, child := range b.children {
+		if other, ok := a.children[r]; ok {
+			n += sharedNodes(other, child)
+		}
+	}
+	return n
+}
+
+func TestImmutableTrie(t *testing.T) {
+	v1 := NewImmutableTrie()
+	for _, s := range []string{`apple`, `application`, `banana`, `cherry`, `damson`} {
+		v1 = v1.Add(s)
+	}
+
+	v2 := v1.Add(`apply`)
+	if v1.Contains(`apply`) {
+		t.Error("the original version should not contain 'apply'")
+	}
+	if !v2.Contains(`apply`) || !v2.Contains(`apple`) {
+		t.Errorf("the new version should contain 'apply' and 'apple', has %v", v2.Members())
+	}
+
+	// only the root and the 'a', 'p', 'p', 'l' nodes are copied; 'y' is new
+	if shared, total := sharedNodes(v1, v2), v2.Size(); shared != total-5 {
+		t.Errorf("expected %d of %d nodes to be shared, found %d", total-5, total, shared)
+	}
+
+	v3 := v2.Remove(`banana`)
+	if !v2.Contains(`banana`) || v3.Contains(`banana`) {
+		t.Error("removing 'banana' should only affect the new version")
+	}
+	expected := []string{`apple`, `application`, `apply`, `cherry`, `damson`}
+	if !reflect.DeepEqual(v3.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, v3.Members())
+	}
+	if v3.Size() != v2.Size()-len(`banana`) {
+		t.Errorf("removing 'banana' should prune its %d nodes", len(`banana`))
+	}
+
+	if v3.Add(`apple`) != v3 || v3.Remove(`missing`) != v3 {
+		t.Error("no-op changes should return the same version")
+	}
+}
@@ -0,0 +1,89 @@
+/*
+ * iterator.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"iter"
+)
+
+// A TrieIterator retrieves the members of a trie, with their values, one at a
+// time in order.  The trie should not be changed while it is in use.
+type TrieIterator struct {
+	stack []iteratorFrame // the nodes still to be visited, the next on top.
+}
+
+// a node to be visited by a TrieIterator
+type iteratorFrame struct {
+	node   *Trie
+	prefix string // the string leading to the node.
+}
+
+// Iterator returns a TrieIterator positioned before the first member of the
+// trie.
+func (p *Trie) Iterator() *TrieIterator {
+	return &TrieIterator{stack: []iteratorFrame{{p, ``}}}
+}
+
+// Next returns the next member of the trie and its value.  ok is false once
+// every member has been returned.
+func (it *TrieIterator) Next() (key string, value interface{}, ok bool) {
+	for len(it.stack) != 0 {
+		f := it.stack[len(it.stack)-1]
+		it.stack = it.stack[:len(it.stack)-1]
+
+		// push the children in reverse, so the smallest is visited first
+		runes := f.node.sortedRunes()
+		for i := len(runes) - 1; i >= 0; i-- {
+			it.stack = append(it.stack, iteratorFrame{f.node.children[runes[i]], f.prefix + string(runes[i])})
+		}
+
+		if f.node.leaf {
+			return f.prefix, f.node.value, true
+		}
+	}
+	return ``, nil, false
+}
+
+// All returns an iterator over the members of the trie and their values, in
+// order, for use with a range statement.
+func (p *Trie) All() iter.Seq2[string, interface{}] {
+	return func(yield func(string, interface{}) bool) {
+		p.eachLeaf(``, func(key string, leaf *Trie) bool {
+			return yield(key, leaf.value)
+		})
+	}
+}
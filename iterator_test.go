@@ -0,0 +1,113 @@
+/*
+ * iterator_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`help`, 2)
+	trie.AddValue(`hello`, 1)
+	trie.AddValue(`ñu`, 3)
+
+	it := trie.Iterator()
+	expected := []struct {
+		key   string
+		value interface{}
+	}{{`hello`, 1}, {`help`, 2}, {`ñu`, 3}}
+	for _, e := range expected {
+		key, value, ok := it.Next()
+		if !ok || key != e.key || value != e.value {
+			t.Errorf("expected (%s, %v, true) but found (%s, %v, %v)", e.key, e.value, key, value, ok)
+		}
+	}
+
+	if key, value, ok := it.Next(); ok {
+		t.Errorf("expected the iterator to be finished, found (%s, %v)", key, value)
+	}
+	if _, _, ok := it.Next(); ok {
+		t.Error("expected the iterator to stay finished")
+	}
+
+	if _, _, ok := NewTrie().Iterator().Next(); ok {
+		t.Error("expected an empty trie to yield nothing")
+	}
+}
+
+func TestAll(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`help`, 2)
+	trie.AddValue(`hello`, 1)
+	trie.AddValue(`ñu`, 3)
+	trie.AddString(`world`)
+
+	keys := []string{}
+	values := []interface{}{}
+	for k, v := range trie.All() {
+		keys = append(keys, k)
+		values = append(values, v)
+	}
+	if !reflect.DeepEqual(keys, []string{`hello`, `help`, `world`, `ñu`}) {
+		t.Errorf("expected keys in order, found %v", keys)
+	}
+	if !reflect.DeepEqual(values, []interface{}{1, 2, nil, 3}) {
+		t.Errorf("expected values in order, found %v", values)
+	}
+
+	// breaking after the first key stops the walk at 'hello'
+	first := ``
+	for k := range trie.All() {
+		first = k
+		break
+	}
+	if first != `hello` {
+		t.Errorf("expected the first key to be 'hello', found '%s'", first)
+	}
+
+	// nothing more is yielded once yield returns false
+	calls := 0
+	trie.All()(func(string, interface{}) bool {
+		calls++
+		return calls < 2
+	})
+	if calls != 2 {
+		t.Errorf("expected 2 calls to yield before stopping, found %d", calls)
+	}
+}
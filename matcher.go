@@ -0,0 +1,223 @@
+/*
+ * matcher.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// A Match records a single occurrence of a Trie member within a text.
+type Match struct {
+	Key   string      // the member string which was matched.
+	Start int         // the byte offset of the start of the match within the text.
+	End   int         // the byte offset immediately following the match.
+	Value interface{} // the value associated with the member string.
+}
+
+// A Matcher locates the members of a Trie within arbitrary text.
+type Matcher struct {
+	trie *Trie
+	fold bool // whether text is lower-cased before matching.
+}
+
+// NewMatcher creates and returns a Matcher which searches for the members of
+// the given Trie.  If the Trie was created by NewTrieFold, the Matcher is
+// case-insensitive, as if created by NewMatcherFold.
+func NewMatcher(t *Trie) *Matcher {
+	m := new(Matcher)
+	m.trie = t
+	m.fold = t.fold
+	return m
+}
+
+// NewMatcherFold creates and returns a case-insensitive Matcher.  Each rune of
+// the text is lower-cased before being matched, so the members of the Trie
+// should themselves be stored in lower case.  The Key of each Match is the
+// member string, which may differ in case from the matched text.
+func NewMatcherFold(t *Trie) *Matcher {
+	m := NewMatcher(t)
+	m.fold = true
+	return m
+}
+
+// Internal function: calls fn for each member of the trie which starts at byte
+// offset start of text, shortest first.  Stops if fn returns false, and
+// returns false in that case.
+func (m *Matcher) matchesAt(text string, start int, fn func(m Match) bool) bool {
+	p := m.trie
+	folded := []rune{}
+
+	for pos := start; pos < len(text); {
+		r, size := utf8.DecodeRuneInString(text[pos:])
+		pos += size
+
+		if m.fold {
+			r = unicode.ToLower(r)
+			folded = append(folded, r)
+		}
+
+		child, ok := p.children[r]
+		if !ok {
+			break
+		}
+
+		if child.leaf {
+			key := text[start:pos]
+			if m.fold {
+				key = string(folded)
+			}
+			if !fn(Match{key, start, pos, child.value}) {
+				return false
+			}
+		}
+
+		p = child
+	}
+
+	return true
+}
+
+// FindAll returns every occurrence of every member of the trie within text,
+// including overlapping matches.  Matches are ordered by their starting
+// offset, and then by length.
+func (m *Matcher) FindAll(text string) []Match {
+	matches := []Match{}
+	m.FindAllFunc(text, func(match Match) bool {
+		matches = append(matches, match)
+		return true
+	})
+	return matches
+}
+
+// FindAllFunc calls fn for every occurrence of every member of the trie
+// within text, in the same order as FindAll, without building a slice of the
+// matches.  The search stops if fn returns false.
+func (m *Matcher) FindAllFunc(text string, fn func(m Match) bool) {
+	for start := range text {
+		if !m.matchesAt(text, start, fn) {
+			return
+		}
+	}
+}
+
+// FindLongest returns the leftmost-longest non-overlapping matches within
+// text.  Scanning from left to right, the longest member starting at each
+// position is taken, and scanning resumes immediately after it.
+func (m *Matcher) FindLongest(text string) []Match {
+	matches := []Match{}
+
+	start := 0
+	for start < len(text) {
+		var longest *Match
+		m.matchesAt(text, start, func(match Match) bool {
+			longest = &match
+			return true
+		})
+
+		if longest != nil {
+			matches = append(matches, *longest)
+			start = longest.End
+			continue
+		}
+
+		// no match here, so move on to the next rune
+		_, size := utf8.DecodeRuneInString(text[start:])
+		start += size
+	}
+
+	return matches
+}
+
+// Coverage returns the fraction of the runes of text which are covered by the
+// leftmost-longest matches found by FindLongest.  An empty text has no
+// coverage.
+func (m *Matcher) Coverage(text string) float64 {
+	total := utf8.RuneCountInString(text)
+	if total == 0 {
+		return 0
+	}
+
+	// the matches don't overlap, so their union is simply their sum
+	covered := 0
+	for _, match := range m.FindLongest(text) {
+		covered += utf8.RuneCountInString(text[match.Start:match.End])
+	}
+
+	return float64(covered) / float64(total)
+}
+
+// ReplaceAllPreserveCase returns a copy of text in which each of the
+// leftmost-longest matches found by FindLongest is replaced by the result of
+// repl.  repl is passed both the member string and the text it matched, which
+// may differ in case if the Matcher is case-insensitive.
+func (m *Matcher) ReplaceAllPreserveCase(text string, repl func(key, matchedText string) string) string {
+	var b strings.Builder
+
+	last := 0
+	for _, match := range m.FindLongest(text) {
+		b.WriteString(text[last:match.Start])
+		b.WriteString(repl(match.Key, text[match.Start:match.End]))
+		last = match.End
+	}
+	b.WriteString(text[last:])
+
+	return b.String()
+}
+
+// FindAllContext calls fn for every match found by FindAll, passing up to
+// before runes of the text preceding the match and up to after runes
+// following it.  The context is clipped at the ends of the text.
+func (m *Matcher) FindAllContext(text string, before, after int, fn func(m Match, leftCtx, rightCtx string)) {
+	m.FindAllFunc(text, func(match Match) bool {
+		left := match.Start
+		for i := 0; i < before && left > 0; i++ {
+			_, size := utf8.DecodeLastRuneInString(text[:left])
+			left -= size
+		}
+
+		right := match.End
+		for i := 0; i < after && right < len(text); i++ {
+			_, size := utf8.DecodeRuneInString(text[right:])
+			right += size
+		}
+
+		fn(match, text[left:match.Start], text[match.End:right])
+		return true
+	})
+}
@@ -0,0 +1,188 @@
+/*
+ * matcher_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+func checkMatches(t *testing.T, text string, found []Match, expected []string) {
+	if len(found) != len(expected) {
+		t.Fatalf("expected matches %v in '%s' but found %v", expected, text, found)
+	}
+	for i, m := range found {
+		if m.Key != expected[i] || text[m.Start:m.End] != m.Key {
+			t.Errorf("expected match %d in '%s' to be '%s', found %v", i, text, expected[i], m)
+		}
+	}
+}
+
+func TestMatcherFindAll(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+	trie.AddString(`c`)
+	trie.AddString(`ça`)
+
+	m := NewMatcher(trie)
+	checkMatches(t, `abcc`, m.FindAll(`abcc`), []string{`ab`, `abc`, `c`, `c`})
+	checkMatches(t, `xçab`, m.FindAll(`xçab`), []string{`ça`, `ab`})
+	checkMatches(t, `xyz`, m.FindAll(`xyz`), []string{})
+}
+
+func TestMatcherFindLongest(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+	trie.AddString(`c`)
+
+	m := NewMatcher(trie)
+	checkMatches(t, `abcc`, m.FindLongest(`abcc`), []string{`abc`, `c`})
+	checkMatches(t, `xabyab`, m.FindLongest(`xabyab`), []string{`ab`, `ab`})
+	checkMatches(t, `ééc`, m.FindLongest(`ééc`), []string{`c`})
+}
+
+func TestMatcherCoverage(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+	trie.AddString(`c`)
+	trie.AddString(`é`)
+
+	m := NewMatcher(trie)
+	tests := []struct {
+		text     string
+		expected float64
+	}{
+		{`abcc`, 1.0},
+		{`xyz`, 0.0},
+		{``, 0.0},
+		{`abxx`, 0.5},
+		{`éxxx`, 0.25},
+	}
+	for _, test := range tests {
+		if found := m.Coverage(test.text); found != test.expected {
+			t.Errorf("expected coverage of '%s' to be %v, found %v", test.text, test.expected, found)
+		}
+	}
+}
+
+func TestMatcherReplaceAllPreserveCase(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`apple`, `pear`)
+	trie.AddValue(`über`, `unter`)
+
+	m := NewMatcherFold(trie)
+	keys := []string{}
+	repl := func(key, matched string) string {
+		keys = append(keys, key)
+		v, _ := trie.GetValue(key)
+		pear := v.(string)
+		if matched == strings.ToUpper(matched) {
+			return strings.ToUpper(pear)
+		}
+		if r, _ := utf8.DecodeRuneInString(matched); unicode.IsUpper(r) {
+			return strings.ToUpper(pear[:1]) + pear[1:]
+		}
+		return pear
+	}
+
+	text := `Apple, apple, APPLE and Über`
+	expected := `Pear, pear, PEAR and Unter`
+	if found := m.ReplaceAllPreserveCase(text, repl); found != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, found)
+	}
+	if !reflect.DeepEqual(keys, []string{`apple`, `apple`, `apple`, `über`}) {
+		t.Errorf("the replacer should be passed the stored keys, found %v", keys)
+	}
+
+	// a case-sensitive matcher only finds the exact case
+	expected = `Apple, pear, APPLE and Über`
+	if found := NewMatcher(trie).ReplaceAllPreserveCase(text, repl); found != expected {
+		t.Errorf("expected '%s' but found '%s'", expected, found)
+	}
+}
+
+func TestMatcherFindAllFunc(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+	trie.AddString(`c`)
+
+	m := NewMatcher(trie)
+	text := `abcabcc`
+
+	found := []Match{}
+	m.FindAllFunc(text, func(match Match) bool {
+		found = append(found, match)
+		return true
+	})
+	if !reflect.DeepEqual(found, m.FindAll(text)) {
+		t.Errorf("expected the callback to see %v, saw %v", m.FindAll(text), found)
+	}
+
+	// stop after the third match
+	found = []Match{}
+	m.FindAllFunc(text, func(match Match) bool {
+		found = append(found, match)
+		return len(found) < 3
+	})
+	if !reflect.DeepEqual(found, m.FindAll(text)[:3]) {
+		t.Errorf("expected the callback to see only %v, saw %v", m.FindAll(text)[:3], found)
+	}
+}
+
+func TestMatcherFindAllContext(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`trie`)
+	trie.AddString(`trié`)
+
+	m := NewMatcher(trie)
+	found := []string{}
+	m.FindAllContext(`trie or not, a tries trié`, 3, 4, func(match Match, left, right string) {
+		found = append(found, left+"["+match.Key+"]"+right)
+	})
+
+	expected := []string{`[trie] or `, ` a [trie]s tr`, `es [trié]`}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %q but found %q", expected, found)
+	}
+}
@@ -0,0 +1,132 @@
+/*
+ * node.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import "sort"
+
+// A TrieNode is a read-only view of the sub-trie below one node of a Trie, as
+// returned by Trie.Node and Trie.Child.  Its methods take and return strings
+// relative to the node.  The view shares its nodes with the trie, so it sees
+// later changes made through the trie, but it can't make any itself: the
+// trie's cached sizes and values are only kept up to date by its root.
+type TrieNode struct {
+	root *Trie // supplies the key folding and normalization
+	node *Trie
+}
+
+// Node returns a view of the sub-trie at the end of prefix, relative to this
+// node, or false if no member begins with it.
+func (n TrieNode) Node(prefix string) (TrieNode, bool) {
+	c := n.node.find(n.root.key(prefix))
+	return TrieNode{n.root, c}, c != nil
+}
+
+// Child returns a view of the node reached from this one by r.  The bool is
+// false if there is no such child.
+func (n TrieNode) Child(r rune) (TrieNode, bool) {
+	c, ok := n.node.children[r]
+	return TrieNode{n.root, c}, ok
+}
+
+// Children returns, in order, the runes leading from this node to its
+// children.
+func (n TrieNode) Children() []rune {
+	return n.node.sortedRunes()
+}
+
+// IsLeaf reports whether the string leading to this node is a member.
+func (n TrieNode) IsLeaf() bool {
+	return n.node.leaf
+}
+
+// Value returns the value stored on this node, if any.
+func (n TrieNode) Value() interface{} {
+	return n.node.value
+}
+
+// Contains reports whether the string s, relative to this node, is a member.
+// The empty string stands for the node itself.
+func (n TrieNode) Contains(s string) bool {
+	c := n.node.find(n.root.key(s))
+	return c != nil && c.leaf
+}
+
+// GetValue returns the value stored with the member s, relative to this
+// node.  The bool is false if s is not a member.
+func (n TrieNode) GetValue(s string) (interface{}, bool) {
+	c := n.node.find(n.root.key(s))
+	if c == nil || !c.leaf {
+		return nil, false
+	}
+	return c.value, true
+}
+
+// Members retrieves, in order, the member strings at or below this node,
+// relative to it.
+func (n TrieNode) Members() []string {
+	return n.node.Members()
+}
+
+// AllSuffixes retrieves, in order, the member strings at or below this node,
+// relative to it.  Combined with Node, it gives the completions of a prefix
+// without walking the path from the root again for each keystroke.
+func (n TrieNode) AllSuffixes() []string {
+	return n.node.Members()
+}
+
+// PrefixSearch retrieves, in order, the members below this node which begin
+// with prefix, relative to the node.
+func (n TrieNode) PrefixSearch(prefix string) []string {
+	prefix = n.root.key(prefix)
+	c := n.node.find(prefix)
+	if c == nil {
+		return []string{}
+	}
+	members := c.buildMembers(prefix)
+	sort.Strings(members)
+	return members
+}
+
+// Count returns the number of members at or below this node.
+func (n TrieNode) Count() int {
+	return n.node.Count()
+}
+
+// Size returns the number of nodes at or below this one, including itself.
+func (n TrieNode) Size() int {
+	return n.node.Size()
+}
@@ -0,0 +1,143 @@
+/*
+ * pattern_set.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"fmt"
+	"io"
+	"text/scanner"
+)
+
+// A PatternSet manages a set of hyphenation pattern tries, one per language.
+type PatternSet struct {
+	languages map[string]*Trie
+}
+
+// NewPatternSet creates and returns a new, empty PatternSet.
+func NewPatternSet() *PatternSet {
+	ps := new(PatternSet)
+	ps.languages = make(map[string]*Trie)
+	return ps
+}
+
+// LoadTeXPatterns reads TeX-style 'patterns' and 'exceptions' lists of quoted
+// strings, as found in the patterns-en file, and returns a trie holding them.
+// Patterns are added with AddPatternString, and exceptions with
+// AddExceptionString so that they override the patterns for those words.
+func LoadTeXPatterns(r io.Reader) (*Trie, error) {
+	trie := NewTrie()
+	var s scanner.Scanner
+	s.Init(r)
+	s.Mode = scanner.ScanIdents | scanner.ScanStrings | scanner.ScanRawStrings | scanner.SkipComments
+
+	// record the first error found by the scanner itself, such as an unterminated string
+	var scanErr error
+	s.Error = func(s *scanner.Scanner, msg string) {
+		if scanErr == nil {
+			scanErr = fmt.Errorf("trie: %s at position %v", msg, s.Pos())
+		}
+	}
+
+	var which string
+
+	tok := s.Scan()
+	for tok != scanner.EOF && scanErr == nil {
+		switch tok {
+		case scanner.Ident:
+			// we handle two identifiers: 'patterns' and 'exceptions'
+			switch ident := s.TokenText(); ident {
+			case `patterns`, `exceptions`:
+				which = ident
+			default:
+				return nil, fmt.Errorf("trie: unrecognized identifier '%s' at position %v", ident, s.Pos())
+			}
+		case scanner.String, scanner.RawString:
+			// trim the quotes from around the string
+			tokstr := s.TokenText()
+			str := tokstr[1 : len(tokstr)-1]
+
+			switch which {
+			case `patterns`:
+				trie.AddPatternString(str)
+			case `exceptions`:
+				trie.AddExceptionString(str)
+			default:
+				return nil, fmt.Errorf("trie: string '%s' outside of a patterns or exceptions list at position %v", str, s.Pos())
+			}
+		}
+		tok = s.Scan()
+	}
+
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	return trie, nil
+}
+
+// LoadLanguage reads the hyphenation patterns and exceptions for a language
+// from r, replacing any previously loaded for that language.  The input uses
+// the same format as the patterns-en file.
+func (ps *PatternSet) LoadLanguage(lang string, r io.Reader) error {
+	trie, err := LoadTeXPatterns(r)
+	if err != nil {
+		return err
+	}
+
+	if ps.languages == nil {
+		ps.languages = make(map[string]*Trie)
+	}
+	ps.languages[lang] = trie
+	return nil
+}
+
+// HasLanguage reports whether patterns have been loaded for a language.
+func (ps *PatternSet) HasLanguage(lang string) bool {
+	_, ok := ps.languages[lang]
+	return ok
+}
+
+// Hyphenate splits a word into the pieces between which it may be hyphenated
+// according to the patterns of the given language.  If no patterns have been
+// loaded for that language the word is returned unhyphenated, as the sole
+// element of the result; use HasLanguage to tell the two cases apart.
+func (ps *PatternSet) Hyphenate(lang, word string) []string {
+	trie, ok := ps.languages[lang]
+	if !ok {
+		return []string{word}
+	}
+	return trie.Hyphenate(word)
+}
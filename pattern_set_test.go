@@ -0,0 +1,128 @@
+/*
+ * pattern_set_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testPatternsAB = "patterns = {\n    `1b`,\n}\nexceptions = {\n    `ab-ba`,\n}\n"
+const testPatternsBC = "patterns = {\n    `1c`,\n}\n"
+
+func TestPatternSet(t *testing.T) {
+	ps := NewPatternSet()
+
+	if err := ps.LoadLanguage(`ab`, strings.NewReader(testPatternsAB)); err != nil {
+		t.Fatalf("Failed to load patterns for 'ab': %s", err)
+	}
+	if err := ps.LoadLanguage(`bc`, strings.NewReader(testPatternsBC)); err != nil {
+		t.Fatalf("Failed to load patterns for 'bc': %s", err)
+	}
+
+	tests := []struct {
+		lang, word string
+		expected   []string
+	}{
+		{`ab`, `abcabc`, []string{`a`, `bca`, `bc`}},
+		{`bc`, `abcabc`, []string{`ab`, `cab`, `c`}},
+		{`ab`, `abba`, []string{`ab`, `ba`}}, // exception
+		{`bc`, `abba`, []string{`abba`}},
+		{`xx`, `abcabc`, []string{`abcabc`}}, // unknown language
+	}
+	for _, test := range tests {
+		found := ps.Hyphenate(test.lang, test.word)
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("Hyphenating '%s' in '%s': expected %v but found %v", test.word, test.lang, test.expected, found)
+		}
+	}
+
+	if !ps.HasLanguage(`ab`) || ps.HasLanguage(`xx`) {
+		t.Error("HasLanguage should report only the loaded languages")
+	}
+
+	if err := ps.LoadLanguage(`bad`, strings.NewReader("hyphens = { `a1b` }")); err == nil {
+		t.Error("Loading patterns with an unknown identifier should fail")
+	}
+}
+
+// a few patterns from Liang's thesis, and an exception
+const testTeXPatterns = `
+patterns = {
+    "hy3ph",
+    "he2n",
+    "hena4",
+    "hen5at",
+    "1na",
+    "n2at",
+    "1tio",
+    "2io",
+}
+exceptions = {
+    "ta-ble",
+}
+`
+
+func TestLoadTeXPatterns(t *testing.T) {
+	trie, err := LoadTeXPatterns(strings.NewReader(testTeXPatterns))
+	if err != nil {
+		t.Fatalf("Failed to load patterns: %s", err)
+	}
+
+	if pieces := trie.Hyphenate(`hyphenation`); !reflect.DeepEqual(pieces, []string{`hy`, `phen`, `ation`}) {
+		t.Errorf("expected [hy phen ation] but found %v", pieces)
+	}
+	if pieces := trie.Hyphenate(`table`); !reflect.DeepEqual(pieces, []string{`ta`, `ble`}) {
+		t.Errorf("expected the exception [ta ble] but found %v", pieces)
+	}
+	if !trie.Contains(`hyph`) || trie.Contains(`hy3ph`) {
+		t.Error("expected patterns to be stored without their digits")
+	}
+
+	if _, err := LoadTeXPatterns(strings.NewReader("hyphens = { `a1b` }")); err == nil {
+		t.Error("Loading patterns with an unknown identifier should fail")
+	}
+	if _, err := LoadTeXPatterns(strings.NewReader("`a1b`")); err == nil {
+		t.Error("Loading a pattern outside of a list should fail")
+	}
+	for _, input := range []string{"patterns = { `a1b", "patterns = { \"a1b }"} {
+		if _, err := LoadTeXPatterns(strings.NewReader(input)); err == nil {
+			t.Errorf("Loading the unterminated string in '%s' should fail", input)
+		}
+	}
+}
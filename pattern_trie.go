@@ -0,0 +1,105 @@
+/*
+ * pattern_trie.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"strings"
+	"unicode"
+)
+
+// A PatternTrie holds TeX-style hyphenation patterns, storing each pattern's
+// values as ints so that they can be merged without type assertions.
+type PatternTrie struct {
+	patterns *TrieG[[]int] // the patterns, with their digits removed, and their values.
+}
+
+// NewPatternTrie creates and returns a new, empty PatternTrie.
+func NewPatternTrie() *PatternTrie {
+	return &PatternTrie{NewTrieG[[]int]()}
+}
+
+// AddPattern adds a TeX-style hyphenation pattern, of the form '.hy2p'.  Its
+// values are stored with one for each gap between or around its runes, the
+// gaps without a digit having the value zero.
+func (t *PatternTrie) AddPattern(s string) {
+	pure := make([]rune, 0, len(s))
+	v := []int{0}
+
+	for _, r := range s {
+		if unicode.IsDigit(r) {
+			// the value for the gap following the runes so far
+			v[len(v)-1] = int(r - '0')
+			continue
+		}
+		pure = append(pure, r)
+		v = append(v, 0)
+	}
+
+	t.patterns.AddValue(string(pure), v)
+}
+
+// Hyphenate returns the merged values of all the patterns matching word, one
+// for each gap between or around its runes: the value at index i is for the
+// gap before rune i, and the last is for the gap after the final rune.
+// Patterns may be anchored to the start or end of the word with '.'.
+func (t *PatternTrie) Hyphenate(word string) []int {
+	padded := `.` + strings.Map(unicode.ToLower, word) + `.`
+
+	merged := mergePatterns(padded, func(s string, add func(gap int, vals []int)) {
+		// every pattern has a value for the gap before its first rune
+		_, values := t.patterns.AllSubstringsAndValues(s)
+		for _, v := range values {
+			add(0, v)
+		}
+	})
+
+	// drop the gaps outside the padding
+	return merged[1 : len(merged)-1]
+}
+
+// HyphenationPoints returns the rune offsets within word at which it may be
+// hyphenated: the gaps between its runes which have odd merged values.
+func (t *PatternTrie) HyphenationPoints(word string) []int {
+	values := t.Hyphenate(word)
+	points := []int{}
+	for i := 1; i < len(values)-1; i++ {
+		if values[i]%2 == 1 {
+			points = append(points, i)
+		}
+	}
+	return points
+}
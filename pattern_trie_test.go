@@ -0,0 +1,112 @@
+/*
+ * pattern_trie_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestPatternTrie(t *testing.T) {
+	trie := NewPatternTrie()
+	for _, pat := range hyphenationPatterns {
+		trie.AddPattern(pat)
+	}
+
+	// the merged values from Liang's thesis: hy3phen5a4t2ion
+	expected := []int{0, 0, 3, 0, 0, 2, 5, 4, 2, 0, 2, 0}
+	if found := trie.Hyphenate(`hyphenation`); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected values %v but found %v", expected, found)
+	}
+	if found := trie.HyphenationPoints(`Hyphenation`); !reflect.DeepEqual(found, []int{2, 6}) {
+		t.Errorf("expected points [2 6] but found %v", found)
+	}
+
+	// the points agree with those found by a Trie holding the same patterns
+	pieces := hyphenationTrie().Hyphenate(`hyphenation`)
+	if !reflect.DeepEqual(pieces, []string{`hy`, `phen`, `ation`}) {
+		t.Errorf("expected the Trie to agree, found %v", pieces)
+	}
+
+	if found := NewPatternTrie().HyphenationPoints(`word`); len(found) != 0 {
+		t.Errorf("expected no points without patterns, found %v", found)
+	}
+}
+
+func TestPatternTrieAnchored(t *testing.T) {
+	trie := NewPatternTrie()
+	trie.AddPattern(`.ach4`)
+	trie.AddPattern(`5emnix`)
+
+	if found := trie.Hyphenate(`achy`); !reflect.DeepEqual(found, []int{0, 0, 0, 4, 0}) {
+		t.Errorf("expected a value anchored to the start of 'achy', found %v", found)
+	}
+	if found := trie.Hyphenate(`reach`); !reflect.DeepEqual(found, []int{0, 0, 0, 0, 0, 0}) {
+		t.Errorf("expected '.ach' not to match within 'reach', found %v", found)
+	}
+	if found := trie.Hyphenate(`lemnix`); !reflect.DeepEqual(found, []int{0, 5, 0, 0, 0, 0, 0}) {
+		t.Errorf("expected a leading value for 'emnix', found %v", found)
+	}
+}
+
+func TestPatternTrieEnglish(t *testing.T) {
+	f, err := os.Open(`patterns-en`)
+	if err != nil {
+		t.Skipf("Failed to open pattern file: %s", err)
+	}
+	defer f.Close()
+
+	patterns, err := LoadTeXPatterns(f)
+	if err != nil {
+		t.Fatalf("Failed to load patterns: %s", err)
+	}
+	trie := NewPatternTrie()
+	for _, pat := range patterns.ExportPatterns() {
+		trie.AddPattern(pat)
+	}
+
+	tests := map[string][]int{
+		`computer`:    {3, 6},
+		`hyphenation`: {2, 6},
+	}
+	for word, expected := range tests {
+		if found := trie.HyphenationPoints(word); !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected points %v but found %v", word, expected, found)
+		}
+	}
+}
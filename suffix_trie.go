@@ -0,0 +1,76 @@
+/*
+ * suffix_trie.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"sort"
+)
+
+// NewSuffixTrie creates a Trie for finding members by suffix.  Strings are
+// added with AddStringReversed and found with SuffixSearch; storing them
+// reversed trades a second structure for fast suffix queries, since a
+// suffix of a string is a prefix of its reverse.
+func NewSuffixTrie() *Trie {
+	return NewTrie()
+}
+
+// Internal function: returns s with its runes in reverse order.
+func reverseRunes(s string) string {
+	runes := []rune(s)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return string(runes)
+}
+
+// AddStringReversed adds s to the trie with its runes in reverse order, for
+// use with SuffixSearch.
+func (p *Trie) AddStringReversed(s string) {
+	p.AddString(reverseRunes(s))
+}
+
+// SuffixSearch retrieves, in order, all strings added with AddStringReversed
+// which end with suffix.  The strings are returned as they were added, not
+// reversed.
+func (p *Trie) SuffixSearch(suffix string) []string {
+	members := p.PrefixSearch(reverseRunes(suffix))
+	for i, m := range members {
+		members[i] = reverseRunes(m)
+	}
+	sort.Strings(members)
+	return members
+}
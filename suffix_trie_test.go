@@ -0,0 +1,69 @@
+/*
+ * suffix_trie_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSuffixSearch(t *testing.T) {
+	trie := NewSuffixTrie()
+	for _, s := range []string{`nation`, `station`, `hyphenation`, `café`, `résumé`, `naïve`, `cafe`} {
+		trie.AddStringReversed(s)
+	}
+
+	tests := map[string][]string{
+		`ation`: {`hyphenation`, `nation`, `station`},
+		`tion`:  {`hyphenation`, `nation`, `station`},
+		`é`:     {`café`, `résumé`},
+		`umé`:   {`résumé`},
+		`ïve`:   {`naïve`},
+		`fe`:    {`cafe`},
+		`xyz`:   {},
+	}
+	for suffix, expected := range tests {
+		found := trie.SuffixSearch(suffix)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected %v but found %v", suffix, expected, found)
+		}
+	}
+
+	if !trie.Contains(`éfac`) {
+		t.Error("expected 'café' to be stored with its runes reversed")
+	}
+}
@@ -0,0 +1,108 @@
+/*
+ * sync_trie.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"sync"
+)
+
+// A SyncTrie wraps a Trie for use by multiple goroutines.  Any number of
+// readers may use it at once, while writers have exclusive access.  The
+// underlying Trie is not exposed, since calling its methods directly would
+// bypass the lock.
+type SyncTrie struct {
+	mu   sync.RWMutex
+	trie *Trie
+}
+
+// NewSyncTrie creates and returns a new SyncTrie.
+func NewSyncTrie() *SyncTrie {
+	t := new(SyncTrie)
+	t.trie = NewTrie()
+	return t
+}
+
+// AddString adds a string to the trie, as Trie.AddString.
+func (t *SyncTrie) AddString(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.AddString(s)
+}
+
+// AddValue adds a string to the trie with an associated value, as
+// Trie.AddValue.
+func (t *SyncTrie) AddValue(s string, v interface{}) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.trie.AddValue(s, v)
+}
+
+// Remove a string from the trie, as Trie.Remove.
+func (t *SyncTrie) Remove(s string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.trie.Remove(s)
+}
+
+// Contains tests for the inclusion of a string in the trie, as Trie.Contains.
+func (t *SyncTrie) Contains(s string) bool {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Contains(s)
+}
+
+// GetValue returns the value associated with a string, as Trie.GetValue.
+func (t *SyncTrie) GetValue(s string) (interface{}, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.GetValue(s)
+}
+
+// AllSubstringsAndValues returns all anchored substrings of a string within
+// the trie, with their values, as Trie.AllSubstringsAndValues.
+func (t *SyncTrie) AllSubstringsAndValues(s string) ([]string, []interface{}) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.AllSubstringsAndValues(s)
+}
+
+// Members retrieves all member strings, in order, as Trie.Members.
+func (t *SyncTrie) Members() []string {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.trie.Members()
+}
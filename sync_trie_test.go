@@ -0,0 +1,85 @@
+/*
+ * sync_trie_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+)
+
+// Run with 'go test -race' to check for data races.
+func TestSyncTrieConcurrency(t *testing.T) {
+	trie := NewSyncTrie()
+	trie.AddValue(`hyph`, []int32{0, 3, 0, 0})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 500; j++ {
+				if !trie.Contains(`hyph`) {
+					t.Error("trie should always contain 'hyph'")
+					return
+				}
+				if _, ok := trie.GetValue(`hyph`); !ok {
+					t.Error("trie should always have a value for 'hyph'")
+					return
+				}
+				trie.AllSubstringsAndValues(`hyphenation`)
+			}
+		}()
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 100; j++ {
+			trie.AddString(fmt.Sprintf("hyphen%03d", j))
+			trie.AddValue(fmt.Sprintf("hy%03d", j), j)
+			if j%2 == 0 {
+				trie.Remove(fmt.Sprintf("hyphen%03d", j))
+			}
+		}
+	}()
+	wg.Wait()
+
+	if n := len(trie.Members()); n != 151 {
+		t.Errorf("expected 151 members, found %d", n)
+	}
+}
@@ -0,0 +1,149 @@
+/*
+ * topk.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// A TopKTrie counts the occurrences of strings using bounded memory, keeping
+// at most k strings and evicting the least frequent when a new one arrives
+// (the Space-Saving algorithm).  Counts of strings which were added after an
+// eviction are over-estimates by at most the count of the evicted string.
+type TopKTrie struct {
+	counts *Trie         // the counted strings, with their *topKEntry as values.
+	heap   topKEntryHeap // the same entries, the least frequent first.
+	k      int           // the maximum number of strings to count.
+}
+
+// a counted string
+type topKEntry struct {
+	key   string
+	count int
+	index int // the entry's position in the heap.
+}
+
+// a min-heap of counted strings, ordered by count and then by string, so that the first in
+// order of those with the lowest count is evicted
+type topKEntryHeap []*topKEntry
+
+func (h topKEntryHeap) Len() int { return len(h) }
+func (h topKEntryHeap) Less(i, j int) bool {
+	return h[i].count < h[j].count || (h[i].count == h[j].count && h[i].key < h[j].key)
+}
+func (h topKEntryHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index, h[j].index = i, j
+}
+func (h *topKEntryHeap) Push(x interface{}) {
+	e := x.(*topKEntry)
+	e.index = len(*h)
+	*h = append(*h, e)
+}
+func (h *topKEntryHeap) Pop() interface{} {
+	old := *h
+	x := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return x
+}
+
+// NewTopKTrie creates and returns a new TopKTrie which counts at most k
+// strings.
+func NewTopKTrie(k int) *TopKTrie {
+	t := new(TopKTrie)
+	t.counts = NewTrie()
+	t.k = k
+	return t
+}
+
+// AddCount adds n to the count of s.  If s is not already counted and k
+// strings are, the string with the lowest count is evicted and s takes over
+// its count.  Each call takes time logarithmic in k.
+func (t *TopKTrie) AddCount(s string, n int) {
+	if len(s) == 0 || t.k <= 0 {
+		return
+	}
+
+	if v, ok := t.counts.GetValue(s); ok {
+		e := v.(*topKEntry)
+		e.count += n
+		heap.Fix(&t.heap, e.index)
+		return
+	}
+
+	if len(t.heap) < t.k {
+		e := &topKEntry{key: s, count: n}
+		heap.Push(&t.heap, e)
+		t.counts.AddValue(s, e)
+		return
+	}
+
+	// the least frequent entry is taken over by s
+	e := t.heap[0]
+	t.counts.Remove(e.key)
+	e.key = s
+	e.count += n
+	heap.Fix(&t.heap, 0)
+	t.counts.AddValue(s, e)
+}
+
+// Estimate returns the estimated count of s.  The bool is false if s is not
+// currently counted.
+func (t *TopKTrie) Estimate(s string) (int, bool) {
+	v, ok := t.counts.GetValue(s)
+	if !ok {
+		return 0, false
+	}
+	return v.(*topKEntry).count, true
+}
+
+// TopK returns the counted strings, most frequent first.  Strings with equal
+// counts are returned in order.
+func (t *TopKTrie) TopK() []string {
+	entries := append([]*topKEntry{}, t.heap...)
+	sort.Slice(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		return a.count > b.count || (a.count == b.count && a.key < b.key)
+	})
+
+	keys := make([]string, len(entries))
+	for i, e := range entries {
+		keys[i] = e.key
+	}
+	return keys
+}
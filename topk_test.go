@@ -0,0 +1,123 @@
+/*
+ * topk_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func TestTopKTrie(t *testing.T) {
+	top := NewTopKTrie(5)
+
+	// three frequent terms interleaved with a stream of rare ones
+	for i := 0; i < 50; i++ {
+		top.AddCount(`the`, 3)
+		top.AddCount(`and`, 2)
+		if i%2 == 0 {
+			top.AddCount(`trie`, 3)
+		}
+		top.AddCount(fmt.Sprintf("rare%d", i), 1)
+	}
+
+	expected := []string{`the`, `and`, `trie`}
+	if found := top.TopK()[:3]; !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected top terms %v, found %v", expected, found)
+	}
+
+	if count, ok := top.Estimate(`the`); !ok || count < 150 {
+		t.Errorf("expected a count of at least 150 for 'the', found %d (%v)", count, ok)
+	}
+	if _, ok := top.Estimate(`rare0`); ok {
+		t.Error("the rare term 'rare0' should have been evicted")
+	}
+	if len(top.TopK()) != 5 {
+		t.Errorf("exactly five terms should be counted, found %v", top.TopK())
+	}
+}
+
+func TestTopKTrieEvictions(t *testing.T) {
+	// a direct Space-Saving count to compare against, evicting the first in order of the
+	// strings with the lowest count
+	const k = 8
+	reference := make(map[string]int)
+	top := NewTopKTrie(k)
+
+	for i := 0; i < 2000; i++ {
+		s := fmt.Sprintf("s%d", (i*i+7*i)%37)
+		n := i%3 + 1
+		top.AddCount(s, n)
+
+		if _, ok := reference[s]; ok || len(reference) < k {
+			reference[s] += n
+			continue
+		}
+		minKey := ``
+		for key, count := range reference {
+			if minKey == `` || count < reference[minKey] || (count == reference[minKey] && key < minKey) {
+				minKey = key
+			}
+		}
+		reference[s] = reference[minKey] + n
+		delete(reference, minKey)
+	}
+
+	for key, expected := range reference {
+		if count, ok := top.Estimate(key); !ok || count != expected {
+			t.Errorf("expected a count of %d for '%s', found %d (%v)", expected, key, count, ok)
+		}
+	}
+	if len(top.TopK()) != len(reference) {
+		t.Errorf("expected %d counted strings, found %v", len(reference), top.TopK())
+	}
+}
+
+func BenchmarkTopKTrie(b *testing.B) {
+	keys := make([]string, 20000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key%d", i)
+	}
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		top := NewTopKTrie(1000)
+		for _, key := range keys {
+			top.AddCount(key, 1)
+		}
+	}
+}
@@ -39,90 +39,712 @@
 package trie
 
 import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math"
+	"reflect"
 	"sort"
 	"strings"
+	"unicode"
 	"unicode/utf8"
+	"unsafe"
 )
 
+// ErrMaxDepth is returned when adding a string longer than a Trie's MaxDepth.
+var ErrMaxDepth = errors.New("trie: string exceeds the maximum depth")
+
 // A Trie uses runes rather than characters for indexing, therefore its child key values are integers.
 type Trie struct {
 	leaf     bool           // whether the node is a leaf (the end of an input string).
 	value    interface{}    // the value associated with the string up to this leaf node.
 	children map[rune]*Trie // a map of sub-tries for each child rune value.
+	maxValue int            // the largest int value of a member at or below this node.
+	seq      uint64         // the order in which this leaf node became a member.
+	refs     int            // the number of removals needed to remove this member.
+	size     int            // the number of nodes below this node.
+
+	*rootConfig // the state of the whole trie, including MaxDepth; nil below the root.
+}
+
+// the state of a trie which is held only by its root, so as not to enlarge every node
+type rootConfig struct {
+	lastSeq uint64 // the last sequence number given to a member.
+
+	exceptions map[string][]int // explicit hyphenation points, keyed by word.
+	fold       bool             // whether strings are lowercased before use.
+	norm       Normalizer       // the normalization applied to strings before use.
+
+	// MaxDepth, if positive, is the maximum number of runes in a member string.  Longer
+	// strings are rejected, bounding the depth of the trie, and so the work and memory of
+	// the recursive functions which walk it.
+	MaxDepth int
+}
+
+// Internal function: returns a copy of the configuration, with its own map of exceptions,
+// or nil if c is nil.
+func (c *rootConfig) clone() *rootConfig {
+	if c == nil {
+		return nil
+	}
+	d := *c
+	if c.exceptions != nil {
+		d.exceptions = make(map[string][]int, len(c.exceptions))
+		for word, points := range c.exceptions {
+			d.exceptions[word] = points
+		}
+	}
+	return &d
 }
 
 // NewTrie creates and returns a new Trie instance.
 func NewTrie() *Trie {
+	t := newNode()
+	t.rootConfig = new(rootConfig)
+	return t
+}
+
+// Internal function: creates and returns a node to be placed below the root of a trie,
+// without a configuration of its own.
+func newNode() *Trie {
 	t := new(Trie)
 	t.leaf = false
 	t.value = nil
 	t.children = make(map[rune]*Trie)
+	t.maxValue = math.MinInt
+	return t
+}
+
+// NewTrieFold creates and returns a new case-insensitive Trie.  Strings are
+// lowercased before they are added or looked up, so members are stored, and
+// returned by Members() and the searches, in lower case.
+func NewTrieFold() *Trie {
+	t := NewTrie()
+	t.fold = true
+	return t
+}
+
+// A Normalizer converts a string to a normal form, such as one of the Unicode
+// normalization forms.  The Form type of golang.org/x/text/unicode/norm is a
+// Normalizer.
+type Normalizer interface {
+	String(s string) string
+}
+
+// NewTrieNormalized creates and returns a new Trie which normalizes strings
+// with form before they are added or looked up, so that strings with the same
+// normal form are treated as the same member.  Members are stored, and
+// returned by Members(), in their normal form.
+func NewTrieNormalized(form Normalizer) *Trie {
+	t := NewTrie()
+	t.norm = form
 	return t
 }
 
-// Internal function: adds items to the trie, reading runes from a strings.Reader.  It returns
-// the leaf node at which the addition ends.
-func (p *Trie) addRunes(r *strings.Reader) *Trie {
+// Internal function: returns the form of s which is stored in the trie, normalized if the
+// trie has a Normalizer and lowercased if it is case-insensitive.  Every function taking a
+// string from the caller passes it through here before walking the trie.
+func (p *Trie) key(s string) string {
+	if p.norm != nil {
+		s = p.norm.String(s)
+	}
+	if p.fold {
+		return strings.Map(unicode.ToLower, s)
+	}
+	return s
+}
+
+// a RuneReader which lowercases the runes read from r, for case-insensitive tries
+type foldReader struct {
+	r io.RuneReader
+}
+
+func (f foldReader) ReadRune() (rune, int, error) {
+	r, size, err := f.r.ReadRune()
+	return unicode.ToLower(r), size, err
+}
+
+// Internal function: returns a RuneReader giving the runes read from r as key() would
+// give them.  Runes are lowercased for a case-insensitive trie, but a Normalizer can't be
+// applied rune by rune, so they aren't normalized.
+func (p *Trie) keyReader(r io.RuneReader) io.RuneReader {
+	if p.fold {
+		return foldReader{r}
+	}
+	return r
+}
+
+// Internal function: adds items to the trie, reading runes from an io.RuneReader.  It returns
+// the node at which the addition ends, which the caller should pass to setLeaf().  depth is
+// the depth of this node; if maxDepth is positive, going deeper returns ErrMaxDepth, and any
+// nodes created by the addition are removed again.
+func (p *Trie) addRunes(r io.RuneReader, depth, maxDepth int) (*Trie, error) {
 	r0, _, err := r.ReadRune()
 	if err != nil {
-		p.leaf = true
-		return p
+		p.countValueOf(p)
+		return p, nil
+	}
+	if maxDepth > 0 && depth >= maxDepth {
+		return nil, ErrMaxDepth
 	}
 
 	n := p.children[r0]
-	if n == nil {
-		n = NewTrie()
+	created := n == nil
+	if created {
+		n = newNode()
 		p.children[r0] = n
 	}
 
 	// recurse to store sub-runes below the new node
-	return n.addRunes(r)
+	before := n.size
+	leaf, err := n.addRunes(r, depth+1, maxDepth)
+	if err != nil {
+		if created {
+			delete(p.children, r0)
+		}
+		return leaf, err
+	}
+
+	p.size += n.size - before
+	if created {
+		p.size++
+	}
+	p.countValueOf(leaf)
+	return leaf, nil
+}
+
+// Internal function: adds the path s to the trie, like addRunes, but iterating over the
+// runes of s rather than reading them one call at a time.  If maxDepth is positive and s
+// has more runes than that, ErrMaxDepth is returned and the trie is unchanged.
+func (p *Trie) addString(s string, maxDepth int) (*Trie, error) {
+	return addPath(p, stringPath(s), utf8.RuneCountInString(s), maxDepth)
+}
+
+// Internal function: adds the path rs to the trie, as addString does for a string.
+func (p *Trie) addRuneSlice(rs []rune, maxDepth int) (*Trie, error) {
+	return addPath(p, runesPath(rs), len(rs), maxDepth)
+}
+
+// a path of runes to be added by addPath, which decodes the rune at each position
+type runePath interface {
+	stringPath | runesPath
+	runeAt(pos int) (r rune, size int)
+}
+
+type stringPath string
+
+func (s stringPath) runeAt(pos int) (rune, int) {
+	return utf8.DecodeRuneInString(string(s[pos:]))
+}
+
+type runesPath []rune
+
+func (rs runesPath) runeAt(pos int) (rune, int) {
+	return rs[pos], 1
+}
+
+// Internal function used by addString() and addRuneSlice(): adds path, of numRunes runes,
+// below p, keeping the sizes of the nodes along it up to date.
+func addPath[P runePath](p *Trie, path P, numRunes, maxDepth int) (*Trie, error) {
+	if maxDepth > 0 && numRunes > maxDepth {
+		return nil, ErrMaxDepth
+	}
+
+	// find how much of the path already exists, and so how many nodes must be created
+	n := p
+	depth, pos := 0, 0
+	for pos < len(path) {
+		r, size := path.runeAt(pos)
+		child, ok := n.children[r]
+		if !ok {
+			break
+		}
+		n = child
+		depth++
+		pos += size
+	}
+	created := numRunes - depth
+	if created == 0 {
+		return n, nil
+	}
+
+	// every node on the existing path gains all the new nodes
+	n = p
+	for i := 0; i < pos; {
+		r, size := path.runeAt(i)
+		n.size += created
+		n = n.children[r]
+		i += size
+	}
+	n.size += created
+
+	// and each new node those below it
+	for pos < len(path) {
+		r, size := path.runeAt(pos)
+		created--
+		child := newNode()
+		child.size = created
+		n.children[r] = child
+		n = child
+		pos += size
+	}
+	return n, nil
+}
+
+// Internal function: recomputes the size of this node from those of its children.
+func (p *Trie) resize() {
+	p.size = len(p.children)
+	for _, child := range p.children {
+		p.size += child.size
+	}
+}
+
+// Internal function: makes n, a node below this root, a member of the trie.  New members are
+// numbered in the order they were added, and start with a reference count of one.  Returns
+// true if n wasn't already a member.
+func (p *Trie) setLeaf(n *Trie) bool {
+	if n.leaf {
+		return false
+	}
+	n.leaf = true
+	n.refs = 1
+	p.lastSeq++
+	n.seq = p.lastSeq
+	return true
+}
+
+// Internal function: counts the value of n, a node at or below this one which is becoming a
+// member, towards this node's maxValue.  n may already carry an int value, set by
+// SetNodeValue while it wasn't a member.
+func (p *Trie) countValueOf(n *Trie) {
+	if v, ok := n.value.(int); ok && v > p.maxValue {
+		p.maxValue = v
+	}
 }
 
 // AddString adds a string to the trie. If the string is already present, no
-// additional storage happens. Yay!
+// additional storage happens. Yay!  Instead its reference count is increased,
+// and it will take one more call to Remove to remove it.  Strings longer than
+// MaxDepth are ignored; use TryAddString to detect them.
 func (p *Trie) AddString(s string) {
+	p.TryAddString(s)
+}
+
+// TryAddString adds a string to the trie like AddString, but returns
+// ErrMaxDepth, leaving the trie unchanged, if the string is longer than
+// MaxDepth.
+func (p *Trie) TryAddString(s string) error {
+	return p.addKey(p.key(s))
+}
+
+// Internal function used by TryAddString() and AddStringBounded(): adds s, which has already
+// been passed through key(), as a member.
+func (p *Trie) addKey(s string) error {
 	if len(s) == 0 {
-		return
+		return nil
 	}
 
 	// append the runes to the trie -- we're ignoring the value in this invocation
-	p.addRunes(strings.NewReader(s))
+	leaf, err := p.addString(s, p.MaxDepth)
+	if err != nil {
+		return err
+	}
+	if leaf.leaf {
+		leaf.refs++
+	}
+	if p.setLeaf(leaf) {
+		if v, ok := leaf.value.(int); ok {
+			p.raiseMaxValue(s, v)
+		}
+	}
+	return nil
 }
 
 // AddValue adds a string to the trie, with an associated value.  If the string
-// is already present, only the value is updated.
+// is already present, only the value is updated; its reference count is
+// unchanged.  Strings longer than
+// MaxDepth are ignored; use TryAddValue to detect them.
 func (p *Trie) AddValue(s string, v interface{}) {
+	p.TryAddValue(s, v)
+}
+
+// TryAddValue adds a string and its value to the trie like AddValue, but
+// returns ErrMaxDepth, leaving the trie unchanged, if the string is longer
+// than MaxDepth.
+func (p *Trie) TryAddValue(s string, v interface{}) error {
+	s = p.key(s)
 	if len(s) == 0 {
-		return
+		return nil
 	}
 
 	// append the runes to the trie
-	leaf := p.addRunes(strings.NewReader(s))
+	leaf, err := p.addString(s, p.MaxDepth)
+	if err != nil {
+		return err
+	}
+	old := leaf.memberValue()
+	p.setLeaf(leaf)
 	leaf.value = v
+
+	p.valueChanged(s, old, v)
+	return nil
+}
+
+// AddRunes adds the string formed by rs to the trie, as AddString does,
+// without encoding it as UTF-8.  A trie which normalizes its strings, or is
+// case-insensitive, has to convert rs to a string first.
+func (p *Trie) AddRunes(rs []rune) {
+	if p.norm != nil || p.fold {
+		p.AddString(string(rs))
+		return
+	}
+	if len(rs) == 0 {
+		return
+	}
+
+	leaf, err := p.addRuneSlice(rs, p.MaxDepth)
+	if err != nil {
+		return
+	}
+	if leaf.leaf {
+		leaf.refs++
+	}
+	if p.setLeaf(leaf) {
+		if v, ok := leaf.value.(int); ok {
+			p.raiseMaxValue(string(rs), v)
+		}
+	}
+}
+
+// ContainsRunes tests for the inclusion of the string formed by rs in the
+// trie, as Contains does, without encoding it as UTF-8.
+func (p *Trie) ContainsRunes(rs []rune) bool {
+	if p.norm != nil || p.fold {
+		return p.Contains(string(rs))
+	}
+	if len(rs) == 0 {
+		return false
+	}
+
+	for _, r := range rs {
+		child, ok := p.children[r]
+		if !ok {
+			return false
+		}
+		p = child
+	}
+	return p.leaf
+}
+
+// AddAll adds each of strs to the trie, as AddString does.
+func (p *Trie) AddAll(strs []string) {
+	for _, s := range strs {
+		p.AddString(s)
+	}
+}
+
+// AddAllValues adds each of strs to the trie with the value at the same index
+// of values, as AddValue does.  strs and values must be the same length.
+func (p *Trie) AddAllValues(strs []string, values []interface{}) {
+	if len(strs) != len(values) {
+		panic("trie: AddAllValues needs exactly one value per string")
+	}
+	for i, s := range strs {
+		p.AddValue(s, values[i])
+	}
+}
+
+// AppendInt appends id to the []int value associated with a string, adding
+// the string to the trie if necessary.  This is convenient for storing
+// posting lists.  Any value which isn't an []int is replaced.
+func (p *Trie) AppendInt(s string, id int) {
+	s = p.key(s)
+	if len(s) == 0 {
+		return
+	}
+
+	leaf, err := p.addString(s, p.MaxDepth)
+	if err != nil {
+		return
+	}
+	old := leaf.memberValue()
+	p.setLeaf(leaf)
+
+	ids, _ := leaf.value.([]int)
+	leaf.value = append(ids, id)
+	p.valueChanged(s, old, leaf.value)
+}
+
+// Ints returns the []int value associated with a string, as built by
+// AppendInt.  The bool is false if the string is not present or its value is
+// not an []int.
+func (p *Trie) Ints(s string) ([]int, bool) {
+	v, ok := p.GetValue(s)
+	if !ok {
+		return nil, false
+	}
+	ids, ok := v.([]int)
+	return ids, ok
+}
+
+// Internal function: returns the value of this node if it is a member, or nil.  Only the
+// values of members count towards maxValue.
+func (p *Trie) memberValue() interface{} {
+	if p.leaf {
+		return p.value
+	}
+	return nil
+}
+
+// Internal function: recomputes the maxValue of this node from its own value and those of
+// its children.
+func (p *Trie) remax() {
+	p.maxValue = math.MinInt
+	if v, ok := p.memberValue().(int); ok {
+		p.maxValue = v
+	}
+	for _, child := range p.children {
+		if child.maxValue > p.maxValue {
+			p.maxValue = child.maxValue
+		}
+	}
+}
+
+// Internal function: recomputes the maxValue of each of path, a path of nodes from the
+// root, from the bottom up.  An unchanged maxValue leaves those above it unchanged too.
+func remaxPath(path []*Trie) {
+	for i := len(path) - 1; i >= 0; i-- {
+		before := path[i].maxValue
+		path[i].remax()
+		if path[i].maxValue == before {
+			return
+		}
+	}
+}
+
+// Internal function: brings the maxValue of every node along the path s up to date after
+// the value of the member at its end has changed from old, or nil if it wasn't a member,
+// to v.  Raising a value only needs a walk down the path, but lowering or removing an int
+// value needs each node recomputed from its children.
+func (p *Trie) valueChanged(s string, old, v interface{}) {
+	prev, hadInt := old.(int)
+	if n, ok := v.(int); ok && (!hadInt || n >= prev) {
+		p.raiseMaxValue(s, n)
+		return
+	}
+	if !hadInt {
+		return
+	}
+
+	path := []*Trie{p}
+	for _, r := range s {
+		p = p.children[r]
+		path = append(path, p)
+	}
+	remaxPath(path)
+}
+
+// Internal function: records an int value stored at the end of s in the maxValue of every
+// node along its path.
+func (p *Trie) raiseMaxValue(s string, v int) {
+	n := p
+	for _, r := range s {
+		if v > n.maxValue {
+			n.maxValue = v
+		}
+		n = n.children[r]
+	}
+	if v > n.maxValue {
+		n.maxValue = v
+	}
+}
+
+// AddFromRuneReader adds the string formed by all the runes read from r to the
+// trie.  Reading stops at the first error, including io.EOF.  A
+// case-insensitive trie lowercases the runes as they are read, but a trie's
+// Normalizer is not applied to them.
+func (p *Trie) AddFromRuneReader(r io.RuneReader) {
+	p.addFromRuneReader(r)
+}
+
+// Internal function used by AddFromRuneReader() and AddFromReader().  Returns true if a
+// string was added.
+func (p *Trie) addFromRuneReader(r io.RuneReader) bool {
+	r = p.keyReader(r)
+	r0, _, err := r.ReadRune()
+	if err != nil {
+		return false // empty strings can't be added
+	}
+
+	n := p.children[r0]
+	created := n == nil
+	if created {
+		n = newNode()
+		p.children[r0] = n
+	}
+
+	before := n.size
+	leaf, err := n.addRunes(r, 1, p.MaxDepth)
+	if err != nil {
+		if created {
+			delete(p.children, r0)
+		}
+		return false
+	}
+	p.size += n.size - before
+	if created {
+		p.size++
+	}
+	p.countValueOf(leaf)
+	if leaf.leaf {
+		leaf.refs++
+	}
+	p.setLeaf(leaf)
+	return true
+}
+
+// a RuneReader which reads a single sep-terminated string from r, reporting
+// io.EOF at its end
+type sepReader struct {
+	r    io.RuneReader
+	sep  rune
+	done bool  // whether the end of the current string has been reached.
+	err  error // the error which ended reading from r, if any.
+}
+
+func (s *sepReader) ReadRune() (rune, int, error) {
+	if s.done {
+		return 0, 0, io.EOF
+	}
+
+	r, size, err := s.r.ReadRune()
+	if err != nil {
+		s.err = err
+		s.done = true
+		return 0, 0, io.EOF
+	}
+	if r == s.sep {
+		s.done = true
+		return 0, 0, io.EOF
+	}
+	return r, size, nil
+}
+
+// AddFromReader adds strings read from r, separated by sep, to the trie
+// without first reading each into a string.  A final string which isn't
+// followed by sep is also added, as is any string interrupted by an error.
+// Empty strings, and those longer than MaxDepth, are skipped.  It returns
+// the number of strings added, and any error other than io.EOF which ended
+// reading.  As with AddFromRuneReader, the strings are lowercased by a
+// case-insensitive trie but not normalized.
+func (p *Trie) AddFromReader(r io.RuneReader, sep rune) (int, error) {
+	sr := &sepReader{r: r, sep: sep}
+	added := 0
+
+	for sr.err == nil {
+		sr.done = false
+		if p.addFromRuneReader(sr) {
+			added++
+		}
+
+		// skip the remainder of a string which was too long
+		for !sr.done {
+			sr.ReadRune()
+		}
+	}
+
+	if sr.err == io.EOF {
+		return added, nil
+	}
+	return added, sr.err
+}
+
+// AddStringBounded adds a string to the trie only if doing so would not push
+// Size() past maxNodes.  If the limit would be exceeded the trie is left
+// unchanged and hitLimit is true.  added is true if the string was stored.
+func (p *Trie) AddStringBounded(s string, maxNodes int) (added bool, hitLimit bool) {
+	// the nodes are counted along the path which will actually be stored
+	s = p.key(s)
+	if len(s) == 0 {
+		return false, false
+	}
+	if p.MaxDepth > 0 && utf8.RuneCountInString(s) > p.MaxDepth {
+		return false, false
+	}
+
+	// count the nodes which would need to be created to store the string
+	n := p
+	newNodes := 0
+	for _, r := range s {
+		if n != nil {
+			n = n.children[r]
+		}
+		if n == nil {
+			newNodes++
+		}
+	}
+
+	if p.Size()+newNodes > maxNodes {
+		return false, true
+	}
+
+	if p.addKey(s) != nil {
+		return false, false
+	}
+	return true, false
 }
 
 // Internal string removal function.  Returns true if this node is empty following the removal.
-func (p *Trie) removeRunes(r *strings.Reader) bool {
+func (p *Trie) removeRunes(r io.RuneReader) bool {
 	r0, _, err := r.ReadRune()
 	if err != nil {
+		if !p.leaf {
+			// not a member, so there is nothing to remove
+			return false
+		}
+		if p.refs > 1 {
+			// the string was added more than once, so it remains a member
+			p.refs--
+			return false
+		}
+
 		// remove value, remove leaf flag
 		p.value = nil
 		p.leaf = false
+		p.refs = 0
+		if p.maxValue != math.MinInt {
+			p.remax()
+		}
 		return len(p.children) == 0
 	}
 
 	child, ok := p.children[r0]
-	if ok && child.removeRunes(r) {
-		// the child is now empty following the removal, so prune it
-		delete(p.children, r0)
+	if ok {
+		before := child.size
+		if child.removeRunes(r) {
+			// the child is now empty following the removal, so prune it
+			delete(p.children, r0)
+			p.size -= before + 1
+		} else {
+			p.size -= before - child.size
+		}
+
+		// a node with no int values below it can't be affected by the removal
+		if p.maxValue != math.MinInt {
+			p.remax()
+		}
 	}
 
-	return len(p.children) == 0
+	// members, and internal nodes carrying a value, are kept
+	return len(p.children) == 0 && !p.leaf && p.value == nil
 }
 
-// Remove a string from the trie.  Returns true if the Trie is now empty.
+// Remove a string from the trie.  Returns true if the Trie is now empty.  A
+// string added more than once by AddString is only removed once Remove has
+// been called as many times; see RefCount.
 func (p *Trie) Remove(s string) bool {
+	s = p.key(s)
 	if len(s) == 0 {
 		return len(p.children) == 0
 	}
@@ -132,7 +754,7 @@ func (p *Trie) Remove(s string) bool {
 }
 
 // Internal string inclusion function.
-func (p *Trie) includes(r *strings.Reader) *Trie {
+func (p *Trie) includes(r io.RuneReader) *Trie {
 	r0, _, err := r.ReadRune()
 	if err != nil {
 		if p.leaf {
@@ -152,16 +774,58 @@ func (p *Trie) includes(r *strings.Reader) *Trie {
 
 // Contains test for the inclusion of a particular string in the Trie.
 func (p *Trie) Contains(s string) bool {
+	s = p.key(s)
 	if len(s) == 0 {
 		return false // empty strings can't be included (how could we add them?)
 	}
 	return p.includes(strings.NewReader(s)) != nil
 }
 
+// RefCount returns the number of times Remove must be called to remove s from
+// the trie: the number of times it was added with AddString, or one if it was
+// only added with AddValue.  Returns zero if s is not a member.
+func (p *Trie) RefCount(s string) int {
+	s = p.key(s)
+	if len(s) == 0 {
+		return 0
+	}
+	if n := p.includes(strings.NewReader(s)); n != nil {
+		return n.refs
+	}
+	return 0
+}
+
+// ContainsPrefix tests whether any member string begins with s, whether or not
+// s is itself a member.  The empty string is a prefix of everything, so
+// it always returns true.
+func (p *Trie) ContainsPrefix(s string) bool {
+	s = p.key(s)
+	return p.find(s) != nil
+}
+
+// ContainsFromRuneReader tests for the inclusion of the string formed by all the
+// runes read from r.  Reading stops at the first error, including io.EOF.  As
+// with AddFromRuneReader, the runes are lowercased by a case-insensitive trie
+// but not normalized.
+func (p *Trie) ContainsFromRuneReader(r io.RuneReader) bool {
+	r = p.keyReader(r)
+	r0, _, err := r.ReadRune()
+	if err != nil {
+		return false // empty strings can't be included
+	}
+
+	child, ok := p.children[r0]
+	if !ok {
+		return false
+	}
+	return child.includes(r) != nil
+}
+
 // GetValue return the value associated with the given string.  Double return:
 // false if the given string was not present, true if the string was present.
 // The value could be both valid and nil.
 func (p *Trie) GetValue(s string) (interface{}, bool) {
+	s = p.key(s)
 	if len(s) == 0 {
 		return nil, false
 	}
@@ -173,87 +837,1487 @@ func (p *Trie) GetValue(s string) (interface{}, bool) {
 	return leaf.value, true
 }
 
-// Internal output-building function used by Members()
-func (p *Trie) buildMembers(prefix string) []string {
-	strList := []string{}
+// GetValueTyped stores the value associated with the given string in the
+// variable pointed to by target.  It returns an error, rather than panicking,
+// if the string is not present or its value can't be assigned to target.
+func (p *Trie) GetValueTyped(s string, target interface{}) error {
+	ptr := reflect.ValueOf(target)
+	if ptr.Kind() != reflect.Ptr || ptr.IsNil() {
+		return fmt.Errorf("trie: target for value of '%s' must be a non-nil pointer, not %T", s, target)
+	}
+	dst := ptr.Elem()
 
-	if p.leaf {
-		strList = append(strList, prefix)
+	value, ok := p.GetValue(s)
+	if !ok {
+		return fmt.Errorf("trie: no value for '%s': string not present", s)
 	}
 
-	// for each child, go grab all suffixes
-	for r, child := range p.children {
-		buf := make([]byte, 4)
-		numChars := utf8.EncodeRune(buf, r)
-		strList = append(strList, child.buildMembers(prefix+string(buf[0:numChars]))...)
+	if value == nil {
+		switch dst.Kind() {
+		case reflect.Chan, reflect.Func, reflect.Interface, reflect.Map, reflect.Ptr, reflect.Slice:
+			dst.Set(reflect.Zero(dst.Type()))
+			return nil
+		}
+		return fmt.Errorf("trie: value for '%s' is nil, which can't be assigned to %v", s, dst.Type())
 	}
 
-	return strList
+	v := reflect.ValueOf(value)
+	if !v.Type().AssignableTo(dst.Type()) {
+		return fmt.Errorf("trie: value for '%s' has type %v, which can't be assigned to %v", s, v.Type(), dst.Type())
+	}
+	dst.Set(v)
+	return nil
 }
 
-// Members retrieves all member strings, in order.
-func (p *Trie) Members() []string {
-	members := p.buildMembers(``)
-	sort.Strings(members)
-	return members
+// SetNodeValue sets the value of the node at the end of prefix, creating the
+// path to it if necessary.  This does not make prefix a member of the trie,
+// so it can be used to attach values to internal nodes.  Returns false if
+// prefix is empty.
+func (p *Trie) SetNodeValue(prefix string, v interface{}) bool {
+	prefix = p.key(prefix)
+	if len(prefix) == 0 {
+		return false
+	}
+
+	n, _ := p.addString(prefix, 0)
+	old := n.memberValue()
+	n.value = v
+	if n.leaf {
+		p.valueChanged(prefix, old, v)
+	}
+	return true
 }
 
-// Size is introspection -- counts all the nodes of the entire Trie, NOT
-// including the root node.
-func (p *Trie) Size() (sz int) {
-	sz = len(p.children)
+// GetNodeValue returns the value of the node at the end of prefix, whether or
+// not prefix is a member of the trie.  The bool is false if prefix is not a
+// path in the trie.
+func (p *Trie) GetNodeValue(prefix string) (interface{}, bool) {
+	prefix = p.key(prefix)
+	if len(prefix) == 0 {
+		return nil, false
+	}
 
-	for _, child := range p.children {
-		sz += child.Size()
+	n := p.find(prefix)
+	if n == nil {
+		return nil, false
 	}
+	return n.value, true
+}
 
-	return
+// Internal output-building function used by Members()
+func (p *Trie) buildMembers(prefix string) []string {
+	return p.appendMembers(append(make([]byte, 0, len(prefix)+32), prefix...), []string{})
 }
 
-// AllSubstrings returns all anchored substrings of the given string within the
-// Trie.
-func (p *Trie) AllSubstrings(s string) []string {
-	v := []string{}
+// Internal function used by buildMembers(): appends the members at or below this node to
+// strList, where buf holds the path to this node.  Each child appends its rune to the same
+// buffer, so only the member strings themselves are allocated.
+func (p *Trie) appendMembers(buf []byte, strList []string) []string {
+	if p.leaf {
+		strList = append(strList, string(buf))
+	}
+
+	// for each child, go grab all suffixes
+	for r, child := range p.children {
+		strList = child.appendMembers(utf8.AppendRune(buf, r), strList)
+	}
+
+	return strList
+}
+
+// Members retrieves all member strings, in order.
+func (p *Trie) Members() []string {
+	members := p.buildMembers(``)
+	sort.Strings(members)
+	return members
+}
+
+// Node returns a read-only view of the sub-trie at the end of prefix, whether
+// or not prefix is itself a member, or false if no member begins with prefix.
+// The view's methods operate on strings relative to prefix.
+func (p *Trie) Node(prefix string) (TrieNode, bool) {
+	n := p.find(p.key(prefix))
+	return TrieNode{p, n}, n != nil
+}
+
+// AllSuffixes retrieves, in order, the member strings at or below this node,
+// relative to it.  Combined with Node, it gives the completions of a prefix
+// without walking the path from the root again for each keystroke.
+func (p *Trie) AllSuffixes() []string {
+	return p.Members()
+}
+
+// PrefixSearch retrieves, in order, all member strings which begin with
+// prefix, including prefix itself if it is a member.
+func (p *Trie) PrefixSearch(prefix string) []string {
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil {
+		return []string{}
+	}
+
+	members := n.buildMembers(prefix)
+	sort.Strings(members)
+	return members
+}
+
+// ValuesUnder retrieves the non-nil values of the member strings which begin
+// with prefix, in the order of those members.  Where every such member has a
+// value, they correspond one-to-one with the results of PrefixSearch.
+func (p *Trie) ValuesUnder(prefix string) []interface{} {
+	values := []interface{}{}
+
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil {
+		return values
+	}
+
+	n.eachLeaf(prefix, func(key string, leaf *Trie) bool {
+		if leaf.value != nil {
+			values = append(values, leaf.value)
+		}
+		return true
+	})
+	return values
+}
+
+// PrefixSearchN retrieves, in order, at most limit member strings which begin
+// with prefix.  The search stops as soon as limit members have been found; if
+// limit is zero or negative all are returned.
+func (p *Trie) PrefixSearchN(prefix string, limit int) []string {
+	members := []string{}
+
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil {
+		return members
+	}
+
+	n.eachLeaf(prefix, func(key string, leaf *Trie) bool {
+		members = append(members, key)
+		return len(members) != limit
+	})
+	return members
+}
+
+// Internal output-building function used by AllNodePrefixes()
+func (p *Trie) buildNodePrefixes(prefix string) []string {
+	strList := []string{}
+
+	for r, child := range p.children {
+		childPrefix := prefix + string(r)
+		strList = append(strList, childPrefix)
+		strList = append(strList, child.buildNodePrefixes(childPrefix)...)
+	}
+
+	return strList
+}
+
+// AllNodePrefixes retrieves, in order, the prefix string of every node in the
+// trie other than the root, whether or not it is a member.
+func (p *Trie) AllNodePrefixes() []string {
+	prefixes := p.buildNodePrefixes(``)
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// Size is introspection -- counts all the nodes of the entire Trie, NOT
+// including the root node.  The count is kept up to date as the trie
+// changes, so this takes constant time.
+func (p *Trie) Size() int {
+	return p.size
+}
 
+// Internal function: calls fn with the length in bytes, and the node, of each member which
+// is an anchored substring of s, shortest first, stopping if fn returns false.  All the
+// AllSubstrings functions slice their matches from this, so they agree on them.
+func (p *Trie) eachSubstring(s string, fn func(end int, leaf *Trie) bool) {
 	for pos, r := range s {
 		child, ok := p.children[r]
 		if !ok {
-			// return whatever we have so far
-			break
+			return
 		}
 
-		// if this is a leaf node, add the string so far to the output vector
-		if child.leaf {
-			v = append(v, s[0:pos])
+		// a match ends after this rune, so it includes all of it
+		if child.leaf && !fn(pos+utf8.RuneLen(r), child) {
+			return
 		}
 
 		p = child
 	}
+}
+
+// AllSubstrings returns all anchored substrings of the given string within the
+// Trie.
+func (p *Trie) AllSubstrings(s string) []string {
+	return p.AllSubstringsN(s, 0)
+}
 
+// AllSubstringsN returns at most n anchored substrings of the given string
+// within the Trie, shortest first.  The walk stops as soon as n have been
+// found; if n is zero or negative all are returned.
+func (p *Trie) AllSubstringsN(s string, n int) []string {
+	s = p.key(s)
+	v := []string{}
+	p.eachSubstring(s, func(end int, leaf *Trie) bool {
+		v = append(v, s[0:end])
+		return len(v) != n
+	})
 	return v
 }
 
 // AllSubstringsAndValues returns all anchored substrings of the given string
 // within the Trie, with a matching set of their associated values.
 func (p *Trie) AllSubstringsAndValues(s string) ([]string, []interface{}) {
+	s = p.key(s)
+	sv := []string{}
+	vv := []interface{}{}
+	p.eachSubstring(s, func(end int, leaf *Trie) bool {
+		sv = append(sv, s[0:end])
+		vv = append(vv, leaf.value)
+		return true
+	})
+	return sv, vv
+}
+
+// AllSubstringsWithValues behaves like AllSubstringsAndValues, but omits any
+// member whose value is nil.  The two returned slices remain index-aligned.
+func (p *Trie) AllSubstringsWithValues(s string) ([]string, []interface{}) {
+	s = p.key(s)
 	sv := []string{}
 	vv := []interface{}{}
+	p.eachSubstring(s, func(end int, leaf *Trie) bool {
+		if leaf.value != nil {
+			sv = append(sv, s[0:end])
+			vv = append(vv, leaf.value)
+		}
+		return true
+	})
+	return sv, vv
+}
+
+// FindPrefixesOf returns every member which is a prefix of s, including s
+// itself if it is a member, shortest first.  It is the dual of PrefixSearch,
+// and returns the same as AllSubstrings.
+func (p *Trie) FindPrefixesOf(s string) []string {
+	return p.AllSubstringsN(s, 0)
+}
+
+// FindPrefixesOfWithValues returns every member which is a prefix of s,
+// shortest first, with a matching set of their associated values.
+func (p *Trie) FindPrefixesOfWithValues(s string) ([]string, []interface{}) {
+	return p.AllSubstringsAndValues(s)
+}
+
+// Internal function used by KeysWithMinValue(). If visits is non-nil it is incremented for
+// every node examined.
+func (p *Trie) buildKeysWithMinValue(prefix string, threshold int, visits *int) []string {
+	if visits != nil {
+		*visits++
+	}
+	if p.maxValue < threshold {
+		// nothing at or below this node can qualify
+		return nil
+	}
+
+	keys := []string{}
+	if n, ok := p.value.(int); ok && p.leaf && n >= threshold {
+		keys = append(keys, prefix)
+	}
+	for r, child := range p.children {
+		keys = append(keys, child.buildKeysWithMinValue(prefix+string(r), threshold, visits)...)
+	}
+
+	return keys
+}
+
+// KeysWithMinValue returns, in order, all member strings whose value is an int no smaller
+// than threshold.  Subtrees which cannot contain such a value are skipped entirely.
+func (p *Trie) KeysWithMinValue(threshold int) []string {
+	keys := p.buildKeysWithMinValue(``, threshold, nil)
+	if keys == nil {
+		return []string{}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Internal function used by Fingerprint()
+func (p *Trie) buildFingerprint(prefix string) uint64 {
+	var sum uint64
+
+	if p.leaf {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%q=%#v", prefix, p.value)
+		sum ^= h.Sum64()
+	}
+
+	for r, child := range p.children {
+		sum ^= child.buildFingerprint(prefix + string(r))
+	}
+
+	return sum
+}
+
+// Fingerprint returns a hash of all member strings and their values.  It does
+// not depend upon the order in which members were added, so two tries with
+// the same contents share the same fingerprint.
+func (p *Trie) Fingerprint() uint64 {
+	return p.buildFingerprint(``)
+}
+
+// Count returns the number of member strings stored in the trie, without
+// building them as Members() does.
+func (p *Trie) Count() (n int) {
+	if p.leaf {
+		n = 1
+	}
+
+	for _, child := range p.children {
+		n += child.Count()
+	}
+
+	return
+}
+
+// CountPrefix returns the number of member strings which begin with prefix,
+// including prefix itself if it is a member, without retrieving them.  The
+// empty prefix counts every member.
+func (p *Trie) CountPrefix(prefix string) int {
+	n := p.find(p.key(prefix))
+	if n == nil {
+		return 0
+	}
+	return n.Count()
+}
+
+// RemovePrefixN removes every member string beginning with prefix.  It returns
+// the number of member strings removed and the number of nodes freed, which
+// includes any ancestors of the prefix left empty by the removal.
+func (p *Trie) RemovePrefixN(prefix string) (keysRemoved, nodesFreed int) {
+	prefix = p.key(prefix)
+	if len(prefix) == 0 {
+		// everything goes
+		keysRemoved, nodesFreed = p.Count(), p.Size()
+		p.children = make(map[rune]*Trie)
+		p.size = 0
+		p.remax()
+		return
+	}
+
+	// find the node at the end of the prefix, remembering the path to it
+	runes := []rune(prefix)
+	path := make([]*Trie, len(runes)+1)
+	path[0] = p
+	for i, r := range runes {
+		child, ok := path[i].children[r]
+		if !ok {
+			return 0, 0 // nothing begins with this prefix
+		}
+		path[i+1] = child
+	}
+
+	n := path[len(runes)]
+	keysRemoved, nodesFreed = n.Count(), n.Size()
+
+	// detach the subtree, then prune any ancestors which are now empty
+	depth := len(runes) - 1
+	for ; depth >= 0; depth-- {
+		parent := path[depth]
+		delete(parent.children, runes[depth])
+		nodesFreed++
+
+		// members, and internal nodes carrying a value, are kept
+		if depth == 0 || parent.leaf || parent.value != nil || len(parent.children) != 0 {
+			break
+		}
+	}
+
+	// the remaining ancestors are smaller by the number of nodes freed, and may have lost
+	// their largest int values
+	for _, n := range path[:depth+1] {
+		n.size -= nodesFreed
+	}
+	remaxPath(path[:depth+1])
+
+	return
+}
+
+// DeleteSubtree removes every member string beginning with prefix, including
+// prefix itself, and returns the number removed.  An empty prefix removes
+// every member.  Use RemovePrefixN to also learn how many nodes were freed.
+func (p *Trie) DeleteSubtree(prefix string) int {
+	removed, _ := p.RemovePrefixN(prefix)
+	return removed
+}
+
+// Internal function: returns the node at the end of the path s, whether or not
+// it is a leaf, or nil if there is no such path.
+func (p *Trie) find(s string) *Trie {
+	for _, r := range s {
+		child, ok := p.children[r]
+		if !ok {
+			return nil
+		}
+		p = child
+	}
+	return p
+}
+
+// Internal function: returns the runes of this node's children, in order.
+func (p *Trie) sortedRunes() []rune {
+	runes := make([]rune, 0, len(p.children))
+	for r := range p.children {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+	return runes
+}
+
+// ChildRunes returns, in order, the runes which may immediately follow prefix
+// within the trie.  The bool is false if prefix is not a path in the trie.
+func (p *Trie) ChildRunes(prefix string) ([]rune, bool) {
+	n := p.find(p.key(prefix))
+	if n == nil {
+		return nil, false
+	}
+	return n.sortedRunes(), true
+}
+
+// Children returns, in order, the runes leading from the root to its
+// children.
+func (p *Trie) Children() []rune {
+	return p.sortedRunes()
+}
+
+// Child returns a read-only view of the node reached from the root by r.  The
+// bool is false if there is no such child.
+func (p *Trie) Child(r rune) (TrieNode, bool) {
+	child, ok := p.children[r]
+	return TrieNode{p, child}, ok
+}
+
+// Internal function used by AppendSuffixToAll(): copies this node and its
+// descendants, grafting suffix onto every leaf.
+func (p *Trie) copyWithSuffix(suffix string) *Trie {
+	n := newNode()
+	n.maxValue = p.maxValue
+
+	// copy the children first, so the grafts below don't see each other
+	for r, child := range p.children {
+		n.children[r] = child.copyWithSuffix(suffix)
+	}
+	n.resize()
+
+	if p.leaf {
+		// the copy keeps the original's place in the insertion order
+		leaf, _ := n.addString(suffix, 0)
+		leaf.leaf = true
+		leaf.seq = p.seq
+		leaf.refs = p.refs
+		leaf.value = p.value
+		if v, ok := p.value.(int); ok {
+			n.raiseMaxValue(suffix, v)
+		}
+	} else {
+		// a value set on an internal node stays with it
+		n.value = p.value
+	}
+
+	return n
+}
+
+// AppendSuffixToAll returns a new Trie whose members are those of this trie
+// with suffix appended, each keeping its associated value.  Values set on
+// other nodes with SetNodeValue stay on the same nodes, and the new trie keeps
+// this one's MaxDepth, Normalizer, hyphenation exceptions, and whether it is
+// case-insensitive.
+func (p *Trie) AppendSuffixToAll(suffix string) *Trie {
+	t := p.copyWithSuffix(p.key(suffix))
+	t.rootConfig = p.rootConfig.clone()
+	return t
+}
+
+// KeyFromPath reconstructs a string by descending from the root, taking the
+// indices[d]-th child, in rune order, at depth d.  The bool is false if any
+// index is out of range or the string is not a member.
+func (p *Trie) KeyFromPath(indices []int) (string, bool) {
+	runes := make([]rune, 0, len(indices))
+	for _, i := range indices {
+		children := p.sortedRunes()
+		if i < 0 || i >= len(children) {
+			return ``, false
+		}
+		runes = append(runes, children[i])
+		p = p.children[children[i]]
+	}
+
+	return string(runes), p.leaf
+}
+
+// Internal function used by KeyAtDFS().  Decrements *n for each leaf visited,
+// returning the key of the leaf at which it reaches -1.
+func (p *Trie) keyAtDFS(prefix string, n *int) (string, bool) {
+	if p.leaf {
+		if *n == 0 {
+			return prefix, true
+		}
+		*n--
+	}
+
+	for _, r := range p.sortedRunes() {
+		if key, ok := p.children[r].keyAtDFS(prefix+string(r), n); ok {
+			return key, true
+		}
+	}
+
+	return ``, false
+}
 
-	for pos, rune := range s {
-		child, ok := p.children[rune]
+// KeyAtDFS returns the n-th (zero-based) member string encountered in a
+// depth-first traversal of the trie, visiting each node before its children
+// and the children in rune order.  The bool is false if there are not that
+// many members.
+func (p *Trie) KeyAtDFS(n int) (string, bool) {
+	if n < 0 {
+		return ``, false
+	}
+	return p.keyAtDFS(``, &n)
+}
+
+// Internal function used by UniquePrefixes()
+func (p *Trie) buildUniquePrefixes(prefix string, prefixes map[string]string) {
+	if p.leaf {
+		// only reached if this member is a prefix of another
+		prefixes[prefix] = prefix
+	}
+
+	for r, child := range p.children {
+		childPrefix := prefix + string(r)
+		if child.Count() == 1 {
+			// no other member shares this path
+			for _, member := range child.buildMembers(childPrefix) {
+				prefixes[member] = childPrefix
+			}
+			continue
+		}
+		child.buildUniquePrefixes(childPrefix, prefixes)
+	}
+}
+
+// UniquePrefixes maps each member string to the shortest prefix of it which no
+// other member shares.  A member which is itself a prefix of another member
+// maps to itself.
+func (p *Trie) UniquePrefixes() map[string]string {
+	prefixes := make(map[string]string)
+	p.buildUniquePrefixes(``, prefixes)
+	return prefixes
+}
+
+// Internal function: returns the first member at or below this node, in order.
+func (p *Trie) first(prefix string) (string, *Trie) {
+	for !p.leaf {
+		if len(p.children) == 0 {
+			return ``, nil
+		}
+		r := p.sortedRunes()[0]
+		prefix += string(r)
+		p = p.children[r]
+	}
+	return prefix, p
+}
+
+// Internal function: returns the last member at or below this node, in order.
+func (p *Trie) last(prefix string) (string, *Trie) {
+	var leafKey string
+	var leaf *Trie
+
+	for {
+		if p.leaf {
+			leafKey, leaf = prefix, p
+		}
+		if len(p.children) == 0 {
+			return leafKey, leaf
+		}
+		runes := p.sortedRunes()
+		r := runes[len(runes)-1]
+		prefix += string(r)
+		p = p.children[r]
+	}
+}
+
+// Internal function used by Next(): returns the first member following prefix+s
+// at or below this node, where this node is at the end of prefix.
+func (p *Trie) next(prefix, s string) (string, *Trie) {
+	if len(s) == 0 {
+		// the first member below this one
+		for _, r := range p.sortedRunes() {
+			if key, leaf := p.children[r].first(prefix + string(r)); leaf != nil {
+				return key, leaf
+			}
+		}
+		return ``, nil
+	}
+
+	r0, size := utf8.DecodeRuneInString(s)
+	if child, ok := p.children[r0]; ok {
+		if key, leaf := child.next(prefix+string(r0), s[size:]); leaf != nil {
+			return key, leaf
+		}
+	}
+
+	// the first member of any following sibling
+	for _, r := range p.sortedRunes() {
+		if r <= r0 {
+			continue
+		}
+		if key, leaf := p.children[r].first(prefix + string(r)); leaf != nil {
+			return key, leaf
+		}
+	}
+
+	return ``, nil
+}
+
+// First returns the first member string in order, with its value.  The bool
+// is false if the trie is empty.
+func (p *Trie) First() (string, interface{}, bool) {
+	key, leaf := p.first(``)
+	if leaf == nil {
+		return ``, nil, false
+	}
+	return key, leaf.value, true
+}
+
+// Last returns the last member string in order, with its value.  The bool is
+// false if the trie is empty.
+func (p *Trie) Last() (string, interface{}, bool) {
+	key, leaf := p.last(``)
+	if leaf == nil {
+		return ``, nil, false
+	}
+	return key, leaf.value, true
+}
+
+// Next returns the first member string following key in order, with its
+// value.  key need not itself be a member.  The bool is false if there are no
+// following members.
+func (p *Trie) Next(key string) (string, interface{}, bool) {
+	next, leaf := p.next(``, p.key(key))
+	if leaf == nil {
+		return ``, nil, false
+	}
+	return next, leaf.value, true
+}
+
+// A SubMatch is an anchored substring found by AllSubstringsFull.
+type SubMatch struct {
+	Str     string      // the matched substring.
+	RuneLen int         // the number of runes in the substring.
+	Value   interface{} // the value associated with the substring.
+}
+
+// AllSubstringsFull returns all anchored substrings of the given string within
+// the Trie, each with its length in runes and its associated value.
+func (p *Trie) AllSubstringsFull(s string) []SubMatch {
+	s = p.key(s)
+	v := []SubMatch{}
+	n, last := 0, 0
+	p.eachSubstring(s, func(end int, leaf *Trie) bool {
+		n += utf8.RuneCountInString(s[last:end])
+		last = end
+		v = append(v, SubMatch{s[0:end], n, leaf.value})
+		return true
+	})
+	return v
+}
+
+// LongestPrefixMatch returns the longest member of the trie which is a prefix
+// of s, along with its value.  The bool is false if no member is a prefix of
+// s.
+func (p *Trie) LongestPrefixMatch(s string) (string, interface{}, bool) {
+	s = p.key(s)
+	var leaf *Trie
+	end := 0
+
+	for pos, r := range s {
+		child, ok := p.children[r]
 		if !ok {
-			// return whatever we have so far
 			break
 		}
 
-		// if this is a leaf node, add the string so far and its value
 		if child.leaf {
-			sv = append(sv, s[0:pos+utf8.RuneLen(rune)])
-			vv = append(vv, child.value)
+			leaf = child
+			end = pos + utf8.RuneLen(r)
 		}
 
 		p = child
 	}
 
-	return sv, vv
+	if leaf == nil {
+		return ``, nil, false
+	}
+	return s[0:end], leaf.value, true
+}
+
+// ShortestPrefixMatch returns the shortest member of the trie which is a
+// prefix of s, along with its value.  The bool is false if no member is a
+// prefix of s.
+func (p *Trie) ShortestPrefixMatch(s string) (string, interface{}, bool) {
+	s = p.key(s)
+	match, value, ok := ``, interface{}(nil), false
+	p.eachSubstring(s, func(end int, leaf *Trie) bool {
+		match, value, ok = s[0:end], leaf.value, true
+		return false
+	})
+	return match, value, ok
+}
+
+// BatchLongestPrefix returns, for each of queries, the longest member of the
+// trie which is a prefix of it, or the empty string if there is none.
+func (p *Trie) BatchLongestPrefix(queries []string) []string {
+	results := make([]string, len(queries))
+	for i, q := range queries {
+		results[i], _, _ = p.LongestPrefixMatch(q)
+	}
+	return results
+}
+
+// PrefixRange returns both the shortest and the longest members of the trie
+// which are prefixes of s, found in a single walk.  The bool is false if no
+// member is a prefix of s.
+func (p *Trie) PrefixRange(s string) (shortest, longest string, ok bool) {
+	s = p.key(s)
+	for pos, r := range s {
+		child, found := p.children[r]
+		if !found {
+			break
+		}
+
+		if child.leaf {
+			longest = s[0 : pos+utf8.RuneLen(r)]
+			if !ok {
+				shortest = longest
+				ok = true
+			}
+		}
+
+		p = child
+	}
+
+	return
+}
+
+// Internal function used by RemovePrefixExcept(): removes every member at or
+// below this node other than the one at the end of s.
+func (p *Trie) retainOnly(s string) {
+	if len(s) == 0 {
+		p.children = make(map[rune]*Trie)
+		p.size = 0
+		p.remax()
+		return
+	}
+
+	if p.leaf {
+		p.leaf = false
+		p.value = nil
+	}
+
+	r0, size := utf8.DecodeRuneInString(s)
+	child := p.children[r0]
+	p.children = map[rune]*Trie{r0: child}
+	child.retainOnly(s[size:])
+	p.resize()
+	p.remax()
+}
+
+// RemovePrefixExcept removes every member string beginning with prefix other
+// than keep, returning the number of members removed.
+func (p *Trie) RemovePrefixExcept(prefix, keep string) int {
+	prefix, keep = p.key(prefix), p.key(keep)
+	if !strings.HasPrefix(keep, prefix) || len(keep) == 0 || p.includes(strings.NewReader(keep)) == nil {
+		removed, _ := p.RemovePrefixN(prefix)
+		return removed
+	}
+
+	n := p.find(prefix)
+	removed, before := n.Count()-1, n.size
+	n.retainOnly(keep[len(prefix):])
+
+	// the ancestors of the prefix are smaller by the number of nodes freed, and may have lost
+	// their largest int values
+	freed := before - n.size
+	path := []*Trie{p}
+	for _, r := range prefix {
+		p.size -= freed
+		p = p.children[r]
+		path = append(path, p)
+	}
+	remaxPath(path[:len(path)-1])
+	return removed
+}
+
+// Internal function used by NearestDistance(): prev is the row of edit
+// distances between the query's prefixes and the path to this node's parent,
+// and r the rune leading to this node.
+func (p *Trie) nearestDistance(r rune, query []rune, prev []int, best *int) {
+	row := make([]int, len(prev))
+	row[0] = prev[0] + 1
+	rowMin := row[0]
+
+	for i := 1; i < len(row); i++ {
+		cost := 1
+		if query[i-1] == r {
+			cost = 0
+		}
+		row[i] = min(row[i-1]+1, prev[i]+1, prev[i-1]+cost)
+		rowMin = min(rowMin, row[i])
+	}
+
+	if p.leaf && row[len(query)] < *best {
+		*best = row[len(query)]
+	}
+
+	// no descendant can be any closer than the best in this row
+	if rowMin >= *best {
+		return
+	}
+	for cr, child := range p.children {
+		child.nearestDistance(cr, query, row, best)
+	}
+}
+
+// NearestDistance returns the smallest edit (Levenshtein) distance between
+// query and any member string, counted in runes.  If no member is within
+// maxDist edits, maxDist+1 is returned.
+func (p *Trie) NearestDistance(query string, maxDist int) int {
+	q := []rune(p.key(query))
+	row := make([]int, len(q)+1)
+	for i := range row {
+		row[i] = i
+	}
+
+	best := maxDist + 1
+	for r, child := range p.children {
+		child.nearestDistance(r, q, row, &best)
+	}
+
+	return best
+}
+
+// Internal function: calls fn for each member at or below this node, in order,
+// with its string and leaf node.  Stops, returning false, if fn returns false.
+func (p *Trie) eachLeaf(prefix string, fn func(key string, leaf *Trie) bool) bool {
+	if p.leaf && !fn(prefix, p) {
+		return false
+	}
+
+	for _, r := range p.sortedRunes() {
+		if !p.children[r].eachLeaf(prefix+string(r), fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MembersByInsertionOrder retrieves all member strings, in the order in which
+// they were first added.  Re-adding an existing member doesn't change its
+// position, but removing and then re-adding it does.
+func (p *Trie) MembersByInsertionOrder() []string {
+	members := []string{}
+	seqs := make(map[string]uint64)
+	p.eachLeaf(``, func(key string, leaf *Trie) bool {
+		members = append(members, key)
+		seqs[key] = leaf.seq
+		return true
+	})
+
+	sort.Slice(members, func(i, j int) bool { return seqs[members[i]] < seqs[members[j]] })
+	return members
+}
+
+// BestUnderPrefix returns the member string beginning with prefix which has
+// the largest int value, along with that value.  Ties are broken in favour of
+// the first in order.  The bool is false if no such member has an int value.
+func (p *Trie) BestUnderPrefix(prefix string) (key string, value interface{}, ok bool) {
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil {
+		return ``, nil, false
+	}
+
+	best := 0
+	n.eachLeaf(prefix, func(k string, leaf *Trie) bool {
+		if v, isInt := leaf.value.(int); isInt && (!ok || v > best) {
+			key, value, ok, best = k, v, true, v
+		}
+		return true
+	})
+
+	return
+}
+
+// TopCompletions returns at most k member strings beginning with prefix,
+// ranked by their int values, largest first.  Ties are broken in favour of
+// the first in order.  Members without an int value are not ranked.
+func (p *Trie) TopCompletions(prefix string, k int) []string {
+	completions := []string{}
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil || k <= 0 {
+		return completions
+	}
+
+	weights := make(map[string]int)
+	n.eachLeaf(prefix, func(key string, leaf *Trie) bool {
+		if v, ok := leaf.value.(int); ok {
+			completions = append(completions, key)
+			weights[key] = v
+		}
+		return true
+	})
+
+	// the completions are in order, so a stable sort breaks ties by it
+	sort.SliceStable(completions, func(i, j int) bool {
+		return weights[completions[i]] > weights[completions[j]]
+	})
+	if len(completions) > k {
+		completions = completions[:k]
+	}
+	return completions
+}
+
+// Internal function used by IsPrefixFree().  Returns whether there are any members at or
+// below this node, and whether any of those is a prefix of another.
+func (p *Trie) prefixFree() (hasMembers, free bool) {
+	below := false
+	for _, child := range p.children {
+		childMembers, childFree := child.prefixFree()
+		if !childFree {
+			return true, false
+		}
+		below = below || childMembers
+	}
+
+	if p.leaf && below {
+		return true, false
+	}
+	return p.leaf || below, true
+}
+
+// IsPrefixFree reports whether the members of the trie form a prefix code,
+// i.e. whether no member is a proper prefix of another.
+func (p *Trie) IsPrefixFree() bool {
+	_, free := p.prefixFree()
+	return free
+}
+
+// Internal function used by InternValues()
+func (p *Trie) internValues(eq func(a, b interface{}) bool, canonical *[]interface{}) (n int) {
+	if p.leaf && p.value != nil {
+		found := false
+		for _, c := range *canonical {
+			if eq(c, p.value) {
+				p.value = c
+				n++
+				found = true
+				break
+			}
+		}
+		if !found {
+			*canonical = append(*canonical, p.value)
+		}
+	}
+
+	for _, child := range p.children {
+		n += child.internValues(eq, canonical)
+	}
+
+	return
+}
+
+// InternValues replaces the values of members which are equal, as judged by
+// eq, with a single shared instance.  It returns the number of values
+// replaced.  Values must not be modified in place after interning, since the
+// change would be seen by every member sharing them.
+func (p *Trie) InternValues(eq func(a, b interface{}) bool) int {
+	canonical := []interface{}{}
+	return p.internValues(eq, &canonical)
+}
+
+// Internal function used by Walk()
+func (p *Trie) walk(prefix string, fn func(key string, value interface{}, isLeaf bool) bool) {
+	for _, r := range p.sortedRunes() {
+		child := p.children[r]
+		key := prefix + string(r)
+		if fn(key, child.value, child.leaf) {
+			child.walk(key, fn)
+		}
+	}
+}
+
+// Walk calls fn for every node of the trie other than the root, depth-first
+// and in order, passing the node's prefix string, its value, and whether it
+// is a leaf.  If fn returns false, the nodes below that one are skipped.
+func (p *Trie) Walk(fn func(key string, value interface{}, isLeaf bool) bool) {
+	p.walk(``, fn)
+}
+
+// WalkFrom calls fn for the node at the end of prefix and every node below it,
+// as Walk does, passing each node's full prefix string.  If prefix is empty
+// it behaves as Walk.  Returns false, without calling fn, if prefix is not a
+// path in the trie.
+func (p *Trie) WalkFrom(prefix string, fn func(key string, value interface{}, isLeaf bool) bool) bool {
+	prefix = p.key(prefix)
+	n := p.find(prefix)
+	if n == nil {
+		return false
+	}
+
+	if len(prefix) == 0 || fn(prefix, n.value, n.leaf) {
+		n.walk(prefix, fn)
+	}
+	return true
+}
+
+// Internal function used by RemoveByValue().  Returns the number of members removed, and
+// whether this node is empty following the removal.
+func (p *Trie) removeByValue(target interface{}, eq func(a, b interface{}) bool) (n int, empty bool) {
+	if p.leaf && eq(p.value, target) {
+		p.leaf = false
+		p.value = nil
+		n++
+	}
+
+	for r, child := range p.children {
+		removed, childEmpty := child.removeByValue(target, eq)
+		n += removed
+		if childEmpty {
+			delete(p.children, r)
+		}
+	}
+	p.resize()
+	p.remax()
+
+	return n, len(p.children) == 0 && !p.leaf && p.value == nil
+}
+
+// RemoveByValue removes every member whose value is equal to target, as
+// judged by eq, returning the number of members removed.  If eq is nil,
+// reflect.DeepEqual is used.
+func (p *Trie) RemoveByValue(target interface{}, eq func(a, b interface{}) bool) int {
+	if eq == nil {
+		eq = reflect.DeepEqual
+	}
+
+	n, _ := p.removeByValue(target, eq)
+	return n
+}
+
+// Internal function used by MembersBefore(): visits the members at or below this node in
+// reverse order, skipping any not strictly before cursor.  Stops, returning false, if fn
+// returns false.
+func (p *Trie) eachLeafBefore(prefix, cursor string, fn func(key string) bool) bool {
+	if prefix >= cursor {
+		// every member below here follows the cursor too
+		return true
+	}
+
+	runes := p.sortedRunes()
+	for i := len(runes) - 1; i >= 0; i-- {
+		r := runes[i]
+		if !p.children[r].eachLeafBefore(prefix+string(r), cursor, fn) {
+			return false
+		}
+	}
+
+	if p.leaf && len(prefix) > 0 {
+		return fn(prefix)
+	}
+	return true
+}
+
+// MembersBefore retrieves, in order, the last limit member strings which sort
+// strictly before cursor; that is, the page of members preceding it.  If
+// limit is zero or negative all such members are returned.
+func (p *Trie) MembersBefore(cursor string, limit int) []string {
+	members := []string{}
+	p.eachLeafBefore(``, p.key(cursor), func(key string) bool {
+		members = append(members, key)
+		return len(members) != limit
+	})
+
+	// the members were found in reverse
+	for i, j := 0, len(members)-1; i < j; i, j = i+1, j-1 {
+		members[i], members[j] = members[j], members[i]
+	}
+	return members
+}
+
+// Internal function used by Merge(): merges other into this node, where root is the root of
+// the trie containing this node.
+func (p *Trie) merge(root, other *Trie) {
+	if other.leaf {
+		root.setLeaf(p)
+		p.value = other.value
+	}
+
+	for r, otherChild := range other.children {
+		child, ok := p.children[r]
+		if !ok {
+			child = newNode()
+			p.children[r] = child
+		}
+		child.merge(root, otherChild)
+	}
+	p.resize()
+	p.remax()
+}
+
+// Merge adds all the members of other, with their values, to the trie.  Where
+// a member is present in both, the value from other replaces the existing
+// one, as AddValue would.  If the trie folds or normalizes its strings, the
+// members of other are folded or normalized in the same way as they're added.
+// other is not modified.
+func (p *Trie) Merge(other *Trie) {
+	if !p.fold && p.norm == nil {
+		p.merge(p, other)
+		return
+	}
+
+	other.eachLeaf(``, func(key string, leaf *Trie) bool {
+		if key = p.key(key); len(key) != 0 {
+			n, _ := p.addString(key, 0)
+			old := n.memberValue()
+			p.setLeaf(n)
+			n.value = leaf.value
+			p.valueChanged(key, old, leaf.value)
+		}
+		return true
+	})
+}
+
+// Internal function used by MatchWildcard(): appends to keys the members below this node
+// which match the remaining pattern.
+func (p *Trie) matchWildcard(prefix, pattern string, keys []string) []string {
+	if len(pattern) == 0 {
+		if p.leaf {
+			keys = append(keys, prefix)
+		}
+		return keys
+	}
+
+	r0, size := utf8.DecodeRuneInString(pattern)
+	if r0 == '?' {
+		for _, r := range p.sortedRunes() {
+			keys = p.children[r].matchWildcard(prefix+string(r), pattern[size:], keys)
+		}
+	} else if child, ok := p.children[r0]; ok {
+		keys = child.matchWildcard(prefix+string(r0), pattern[size:], keys)
+	}
+	return keys
+}
+
+// MatchWildcard returns the member strings matching pattern, in sorted order.
+// Each '?' in the pattern matches exactly one rune; other runes match
+// themselves.
+func (p *Trie) MatchWildcard(pattern string) []string {
+	if len(pattern) == 0 {
+		return []string{}
+	}
+	return p.matchWildcard(``, p.key(pattern), []string{})
+}
+
+// Equal reports whether the trie has the same structure as other: the same
+// nodes, the same members, and the same values, compared with
+// reflect.DeepEqual.  The order in which members were added is ignored.
+func (p *Trie) Equal(other *Trie) bool {
+	if p.leaf != other.leaf || !reflect.DeepEqual(p.value, other.value) {
+		return false
+	}
+	if len(p.children) != len(other.children) {
+		return false
+	}
+
+	for r, child := range p.children {
+		otherChild, ok := other.children[r]
+		if !ok || !child.Equal(otherChild) {
+			return false
+		}
+	}
+	return true
+}
+
+// Internal function used by MembersToDepth(): depth is the number of runes still to be
+// descended below this node.
+func (p *Trie) membersToDepth(prefix string, depth int, complete, truncated []string) ([]string, []string) {
+	if p.leaf {
+		complete = append(complete, prefix)
+	}
+	if depth == 0 {
+		if len(p.children) != 0 {
+			truncated = append(truncated, prefix)
+		}
+		return complete, truncated
+	}
+
+	for _, r := range p.sortedRunes() {
+		complete, truncated = p.children[r].membersToDepth(prefix+string(r), depth-1, complete, truncated)
+	}
+	return complete, truncated
+}
+
+// MembersToDepth retrieves, in order, the member strings of at most maxDepth
+// runes, as complete.  Where longer members were cut off, their maxDepth-rune
+// prefixes are retrieved, in order, as truncated; such a prefix may also be a
+// member in its own right.  Nothing is returned unless maxDepth is positive.
+func (p *Trie) MembersToDepth(maxDepth int) (complete []string, truncated []string) {
+	complete, truncated = []string{}, []string{}
+	if maxDepth <= 0 {
+		return
+	}
+
+	for _, r := range p.sortedRunes() {
+		complete, truncated = p.children[r].membersToDepth(string(r), maxDepth-1, complete, truncated)
+	}
+	return
+}
+
+// LongestCommonPrefix returns the longest string with which every member
+// begins.  It is empty if the trie is empty, or if the members don't all
+// share their first rune.
+func (p *Trie) LongestCommonPrefix() string {
+	var prefix strings.Builder
+	for len(p.children) == 1 && !p.leaf {
+		for r, child := range p.children {
+			prefix.WriteRune(r)
+			p = child
+		}
+	}
+	return prefix.String()
+}
+
+// Internal function used by RuneHistogram()
+func (p *Trie) buildRuneHistogram(counts map[rune]int) {
+	for r, child := range p.children {
+		counts[r]++
+		child.buildRuneHistogram(counts)
+	}
+}
+
+// RuneHistogram returns the number of nodes reached by each rune, across the
+// whole trie.  A rune shared by the prefixes of several members is counted
+// once, as it is stored once.
+func (p *Trie) RuneHistogram() map[rune]int {
+	counts := make(map[rune]int)
+	p.buildRuneHistogram(counts)
+	return counts
+}
+
+// Height returns the number of runes in the longest path from the root, which
+// is the length in runes of the longest member.  An empty trie has a height
+// of zero.
+func (p *Trie) Height() int {
+	h := 0
+	for _, child := range p.children {
+		if ch := child.Height() + 1; ch > h {
+			h = ch
+		}
+	}
+	return h
+}
+
+// Clear removes every member from the trie, so that it can be reused.  Its
+// MaxDepth, Normalizer, and whether it is case-insensitive, are kept.
+func (p *Trie) Clear() {
+	config := p.rootConfig
+	*p = *NewTrie()
+	if config != nil {
+		p.MaxDepth, p.fold, p.norm = config.MaxDepth, config.fold, config.norm
+	}
+}
+
+// Internal function used by Retain(): removes every member at or below this node which
+// isn't below one of the kept nodes.  Returns the number of members removed, and whether
+// this node is empty following the removal.
+func (p *Trie) retain(kept map[*Trie]bool) (n int, empty bool) {
+	if kept[p] {
+		return 0, false
+	}
+
+	if p.leaf {
+		p.leaf = false
+		p.value = nil
+		p.refs = 0
+		n++
+	}
+
+	for r, child := range p.children {
+		removed, childEmpty := child.retain(kept)
+		n += removed
+		if childEmpty {
+			delete(p.children, r)
+		}
+	}
+	p.resize()
+	p.remax()
+
+	return n, len(p.children) == 0 && p.value == nil
+}
+
+// Retain removes every member string which doesn't begin with one of
+// prefixes, returning the number of members removed.
+func (p *Trie) Retain(prefixes []string) int {
+	// mark the nodes whose subtrees are kept whole, then prune the rest
+	kept := make(map[*Trie]bool)
+	for _, prefix := range prefixes {
+		if n := p.find(p.key(prefix)); n != nil {
+			kept[n] = true
+		}
+	}
+
+	n, _ := p.retain(kept)
+	return n
+}
+
+// TrieStats summarizes the shape of a trie, as returned by Stats.
+type TrieStats struct {
+	Nodes    int     // the number of nodes, not including the root.
+	Leaves   int     // the number of member strings.
+	MaxDepth int     // the number of runes in the longest path, as returned by Height.
+	AvgDepth float64 // the mean number of runes in a member string.
+}
+
+// Internal function used by Stats(): adds this node, at the given depth, and its
+// descendants to stats, along with the depths of their leaves to totalDepth.
+func (p *Trie) buildStats(depth int, stats *TrieStats, totalDepth *int) {
+	if p.leaf {
+		stats.Leaves++
+		*totalDepth += depth
+	}
+	if depth > stats.MaxDepth {
+		stats.MaxDepth = depth
+	}
+
+	for _, child := range p.children {
+		stats.Nodes++
+		child.buildStats(depth+1, stats, totalDepth)
+	}
+}
+
+// Stats returns the number of nodes and members of the trie, and the maximum
+// and mean depths of its members, found in a single pass.
+func (p *Trie) Stats() TrieStats {
+	var stats TrieStats
+	totalDepth := 0
+	p.buildStats(0, &stats, &totalDepth)
+
+	if stats.Leaves > 0 {
+		stats.AvgDepth = float64(totalDepth) / float64(stats.Leaves)
+	}
+	return stats
+}
+
+// Internal function used by With(): returns a copy of this node, sharing its children.  A
+// copy of the root has its own configuration.
+func (p *Trie) copyNode() *Trie {
+	n := new(Trie)
+	*n = *p
+	n.rootConfig = p.rootConfig.clone()
+	n.children = make(map[rune]*Trie, len(p.children))
+	for r, child := range p.children {
+		n.children[r] = child
+	}
+	return n
+}
+
+// With returns a new version of the trie to which s has been added with the
+// value v, as by AddValue, leaving this trie unchanged.  Only the nodes along
+// the path of s are copied; the versions share all other nodes, so neither
+// should be changed by other means once With has been used.
+func (p *Trie) With(s string, v interface{}) *Trie {
+	s = p.key(s)
+	root := p.copyNode()
+
+	numRunes := utf8.RuneCountInString(s)
+	if numRunes == 0 || (p.MaxDepth > 0 && numRunes > p.MaxDepth) {
+		return root
+	}
+
+	// count the nodes which must be created
+	depth := 0
+	n := p
+	for _, r := range s {
+		child, ok := n.children[r]
+		if !ok {
+			break
+		}
+		n = child
+		depth++
+	}
+	created := numRunes - depth
+
+	// copy the existing path, each copy gaining all the new nodes, then add the new ones
+	n = root
+	n.size += created
+	i := 0
+	for _, r := range s {
+		child, ok := n.children[r]
+		if ok {
+			child = child.copyNode()
+			child.size += created
+		} else {
+			child = newNode()
+			child.size = numRunes - i - 1
+		}
+		n.children[r] = child
+		n = child
+		i++
+	}
+
+	old := n.memberValue()
+	root.setLeaf(n)
+	n.value = v
+	root.valueChanged(s, old, v)
+	return root
+}
+
+// approximate sizes used by EstimatedBytes, in bytes
+const (
+	mapHeaderBytes = 48 // a map's header.
+	mapEntryBytes  = 24 // a map entry's key, value & control data, allowing for spare capacity.
+)
+
+// Internal function used by EstimatedBytes(): estimates the heap space used by a value,
+// beyond the interface holding it.
+func valueBytes(v interface{}) int {
+	switch v := v.(type) {
+	case []int32:
+		return int(unsafe.Sizeof(v)) + 4*cap(v)
+	case []int:
+		return int(unsafe.Sizeof(v)) + int(unsafe.Sizeof(0))*cap(v)
+	case string:
+		return int(unsafe.Sizeof(v)) + len(v)
+	case int:
+		return int(unsafe.Sizeof(v))
+	}
+	return 0
+}
+
+// EstimatedBytes returns an approximation of the heap space used by the trie:
+// its nodes, their maps of children, and values of the types nil, int,
+// string, []int and []int32.  Other values are counted only as the interface
+// holding them, and the actual figure depends upon the Go runtime, so this is
+// for comparison rather than accounting.
+func (p *Trie) EstimatedBytes() int {
+	n := int(unsafe.Sizeof(*p)) + mapHeaderBytes + mapEntryBytes*len(p.children) + valueBytes(p.value)
+	if p.rootConfig != nil {
+		n += int(unsafe.Sizeof(*p.rootConfig))
+	}
+	for _, child := range p.children {
+		n += child.EstimatedBytes()
+	}
+	return n
 }
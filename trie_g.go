@@ -0,0 +1,206 @@
+/*
+ * trie_g.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"sort"
+	"unicode/utf8"
+)
+
+// A TrieG is a Trie whose values all have the type V, so that they can be
+// retrieved without type assertions.  Its nodes are arranged exactly as a
+// Trie's are.
+type TrieG[V any] struct {
+	leaf     bool               // whether the node is a leaf (the end of an input string).
+	value    V                  // the value associated with the string up to this leaf node.
+	children map[rune]*TrieG[V] // a map of sub-tries for each child rune value.
+}
+
+// NewTrieG creates and returns a new TrieG instance.
+func NewTrieG[V any]() *TrieG[V] {
+	return &TrieG[V]{children: make(map[rune]*TrieG[V])}
+}
+
+// AddString adds a string to the trie, with the zero value of V if it isn't
+// already present.
+func (p *TrieG[V]) AddString(s string) {
+	if len(s) == 0 {
+		return
+	}
+	p.add(s).leaf = true
+}
+
+// AddValue adds a string to the trie, with an associated value.  If the
+// string is already present, only the value is updated.
+func (p *TrieG[V]) AddValue(s string, v V) {
+	if len(s) == 0 {
+		return
+	}
+	n := p.add(s)
+	n.leaf = true
+	n.value = v
+}
+
+// Internal function: returns the node at the end of the path s, creating it if necessary.
+func (p *TrieG[V]) add(s string) *TrieG[V] {
+	for _, r := range s {
+		child, ok := p.children[r]
+		if !ok {
+			child = NewTrieG[V]()
+			p.children[r] = child
+		}
+		p = child
+	}
+	return p
+}
+
+// Internal function: returns the node at the end of the path s, whether or not it is a
+// leaf, or nil if there is no such path.
+func (p *TrieG[V]) find(s string) *TrieG[V] {
+	for _, r := range s {
+		child, ok := p.children[r]
+		if !ok {
+			return nil
+		}
+		p = child
+	}
+	return p
+}
+
+// Contains tests for the inclusion of a particular string in the trie.
+func (p *TrieG[V]) Contains(s string) bool {
+	if len(s) == 0 {
+		return false
+	}
+	n := p.find(s)
+	return n != nil && n.leaf
+}
+
+// GetValue returns the value associated with the given string.  The bool is
+// false, and the value is the zero value of V, if s is not a member.
+func (p *TrieG[V]) GetValue(s string) (V, bool) {
+	var zero V
+	if len(s) == 0 {
+		return zero, false
+	}
+
+	n := p.find(s)
+	if n == nil || !n.leaf {
+		return zero, false
+	}
+	return n.value, true
+}
+
+// Internal string removal function.  Returns true if this node is empty following the removal.
+func (p *TrieG[V]) remove(s string) bool {
+	if len(s) == 0 {
+		var zero V
+		p.value = zero
+		p.leaf = false
+		return len(p.children) == 0
+	}
+
+	r0, size := utf8.DecodeRuneInString(s)
+	child, ok := p.children[r0]
+	if ok && child.remove(s[size:]) {
+		delete(p.children, r0)
+	}
+	return len(p.children) == 0 && !p.leaf
+}
+
+// Remove a string from the trie.  Returns true if the trie is now empty.
+func (p *TrieG[V]) Remove(s string) bool {
+	if len(s) == 0 {
+		return len(p.children) == 0
+	}
+	return p.remove(s)
+}
+
+// Internal recursive function used by Members().
+func (p *TrieG[V]) buildMembers(prefix string) []string {
+	strList := []string{}
+
+	if p.leaf {
+		strList = append(strList, prefix)
+	}
+	for r, child := range p.children {
+		strList = append(strList, child.buildMembers(prefix+string(r))...)
+	}
+
+	return strList
+}
+
+// Members retrieves, in order, all member strings of the trie.
+func (p *TrieG[V]) Members() []string {
+	members := p.buildMembers(``)
+	sort.Strings(members)
+	return members
+}
+
+// Size returns the number of nodes in the trie, not counting the root.
+func (p *TrieG[V]) Size() (sz int) {
+	sz = len(p.children)
+
+	for _, child := range p.children {
+		sz += child.Size()
+	}
+
+	return
+}
+
+// AllSubstringsAndValues returns all anchored substrings of the given string
+// within the trie, with a matching set of their associated values.
+func (p *TrieG[V]) AllSubstringsAndValues(s string) ([]string, []V) {
+	sv := []string{}
+	vv := []V{}
+
+	for pos, r := range s {
+		child, ok := p.children[r]
+		if !ok {
+			break
+		}
+
+		if child.leaf {
+			sv = append(sv, s[0:pos+utf8.RuneLen(r)])
+			vv = append(vv, child.value)
+		}
+
+		p = child
+	}
+
+	return sv, vv
+}
@@ -0,0 +1,111 @@
+/*
+ * trie_g_test.go
+ * Trie
+ *
+ * Copyright (c) 2010 Jim Dovey
+ * All rights reserved.
+ *
+ * Redistribution and use in source and binary forms, with or without
+ * modification, are permitted provided that the following conditions
+ * are met:
+ *
+ * Redistributions of source code must retain the above copyright notice,
+ * this list of conditions and the following disclaimer.
+ *
+ * Redistributions in binary form must reproduce the above copyright
+ * notice, this list of conditions and the following disclaimer in the
+ * documentation and/or other materials provided with the distribution.
+ *
+ * Neither the name of the project's author nor the names of its
+ * contributors may be used to endorse or promote products derived from
+ * this software without specific prior written permission.
+ *
+ * THIS SOFTWARE IS PROVIDED BY THE COPYRIGHT HOLDERS AND CONTRIBUTORS
+ * "AS IS" AND ANY EXPRESS OR IMPLIED WARRANTIES, INCLUDING, BUT NOT
+ * LIMITED TO, THE IMPLIED WARRANTIES OF MERCHANTABILITY AND FITNESS
+ * FOR A PARTICULAR PURPOSE ARE DISCLAIMED. IN NO EVENT SHALL THE COPYRIGHT
+ * HOLDER OR CONTRIBUTORS BE LIABLE FOR ANY DIRECT, INDIRECT, INCIDENTAL,
+ * SPECIAL, EXEMPLARY, OR CONSEQUENTIAL DAMAGES (INCLUDING, BUT NOT LIMITED
+ * TO, PROCUREMENT OF SUBSTITUTE GOODS OR SERVICES; LOSS OF USE, DATA, OR
+ * PROFITS; OR BUSINESS INTERRUPTION) HOWEVER CAUSED AND ON ANY THEORY OF
+ * LIABILITY, WHETHER IN CONTRACT, STRICT LIABILITY, OR TORT (INCLUDING
+ * NEGLIGENCE OR OTHERWISE) ARISING IN ANY WAY OUT OF THE USE OF THIS
+ * SOFTWARE, EVEN IF ADVISED OF THE POSSIBILITY OF SUCH DAMAGE.
+ *
+ */
+
+package trie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTrieGValues(t *testing.T) {
+	trie := NewTrieG[[]int32]()
+
+	str := "hyphenation"
+	hyp := []int32{0, 3, 0, 0, 2, 5, 4, 2, 0, 2, 0}
+
+	trie.AddValue(str, hyp)
+	if !trie.Contains(str) {
+		t.Error("trie should contain the word 'hyphenation'")
+	}
+	if trie.Size() != len(str) {
+		t.Errorf("trie should have %d nodes, instead has %d", len(str), trie.Size())
+	}
+
+	v, ok := trie.GetValue(str)
+	if !ok || !reflect.DeepEqual(v, hyp) {
+		t.Errorf("expected (%v, true) but found (%v, %v)", hyp, v, ok)
+	}
+
+	v, ok = trie.GetValue(`hyphen`)
+	if ok || v != nil {
+		t.Errorf("expected (nil, false) for a non-member but found (%v, %v)", v, ok)
+	}
+
+	if !trie.Remove(str) || trie.Contains(str) || trie.Size() != 0 {
+		t.Errorf("trie should be empty after removing '%s'", str)
+	}
+}
+
+func TestTrieGMultiFindValue(t *testing.T) {
+	trie := NewTrieG[[]int32]()
+	trie.AddValue(`hy`, []int32{0, 3, 0})
+	trie.AddValue(`hyph`, []int32{0, 3, 0, 0})
+	trie.AddString(`hyphen`)
+	trie.AddValue(`hen`, []int32{2, 0, 0})
+
+	found, values := trie.AllSubstringsAndValues(`hyphenation`)
+	expectedFound := []string{`hy`, `hyph`, `hyphen`}
+	expectedValues := [][]int32{{0, 3, 0}, {0, 3, 0, 0}, nil}
+	if !reflect.DeepEqual(found, expectedFound) {
+		t.Errorf("expected substrings %v but found %v", expectedFound, found)
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("expected values %v but found %v", expectedValues, values)
+	}
+
+	// the values need no type assertion
+	total := int32(0)
+	for _, v := range values {
+		for _, n := range v {
+			total += n
+		}
+	}
+	if total != 6 {
+		t.Errorf("expected the values to total 6 but found %d", total)
+	}
+
+	expectedMembers := []string{`hen`, `hy`, `hyph`, `hyphen`}
+	if !reflect.DeepEqual(trie.Members(), expectedMembers) {
+		t.Errorf("expected members %v but found %v", expectedMembers, trie.Members())
+	}
+
+	// removing a prefix keeps the longer members
+	trie.Remove(`hy`)
+	if trie.Contains(`hy`) || !trie.Contains(`hyph`) {
+		t.Errorf("expected only 'hy' to be removed, found %v", trie.Members())
+	}
+}
@@ -39,11 +39,15 @@
 package trie
 
 import (
+	"bytes"
 	"fmt"
 	"io"
+	"math"
 	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
-	"text/scanner"
 	"unicode/utf8"
 )
 
@@ -100,6 +104,12 @@ func TestTrie(t *testing.T) {
 		t.Error("trie should contain exactly three member strings")
 	}
 
+	// it was added twice, so the first removal leaves it in place
+	trie.Remove("hello, world!")
+	if !trie.Contains("hello, world!") {
+		t.Error("trie should still contain 'hello, world!' after removing it once")
+	}
+
 	// remove a string-- should reduce the size by the number of unique characters in that string
 	trie.Remove("hello, world!")
 	if trie.Contains("hello, world!") {
@@ -123,13 +133,29 @@ func TestMultiFind(t *testing.T) {
 
 	expected := []string{`hyph`}
 	found := trie.AllSubstrings(`hyphenation`)
-	if len(found) != len(expected) {
+	if !reflect.DeepEqual(found, expected) {
 		t.Errorf("expected %v but found %v", expected, found)
 	}
 
 	expected = []string{`hen`, `hena`, `henat`}
 	found = trie.AllSubstrings(`henation`)
-	if len(found) != len(expected) {
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	// a member equal to the whole input is included
+	expected = []string{`hen`, `hena`, `henat`}
+	found = trie.AllSubstrings(`henat`)
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	// matches ending in a multibyte rune include all of it
+	trie.AddString(`ñ`)
+	trie.AddString(`ñandú`)
+	expected = []string{`ñ`, `ñandú`}
+	found = trie.AllSubstrings(`ñandú`)
+	if !reflect.DeepEqual(found, expected) {
 		t.Errorf("expected %v but found %v", expected, found)
 	}
 }
@@ -296,77 +322,2577 @@ func TestMultiFindValue(t *testing.T) {
 	}
 }
 
-//////////////////////////////////////////////////////////////////
-// Benchmarks
-// Run like so:
-//   cat patterns-en.go | gotest -benchmarks=".*"
-// This is because, for some unknown reason, os.Open() always returns 'resource temporarily unavailable'.
-
-func loadPatterns(reader io.Reader) (*Trie, error) {
+func TestAddStringBounded(t *testing.T) {
 	trie := NewTrie()
-	var s scanner.Scanner
-	s.Init(reader)
-	s.Mode = scanner.ScanIdents | scanner.ScanRawStrings | scanner.SkipComments
 
-	var which string
+	added, hit := trie.AddStringBounded(`hello`, 8)
+	if !added || hit {
+		t.Fatalf("'hello' should fit within a budget of 8 nodes (added=%v, hitLimit=%v)", added, hit)
+	}
+
+	// 'help' only needs one new node
+	added, hit = trie.AddStringBounded(`help`, 8)
+	if !added || hit {
+		t.Fatalf("'help' should fit within a budget of 8 nodes (added=%v, hitLimit=%v)", added, hit)
+	}
+
+	// 'world' needs five more nodes, which would exceed the budget
+	added, hit = trie.AddStringBounded(`world`, 8)
+	if added || !hit {
+		t.Errorf("'world' should have been rejected (added=%v, hitLimit=%v)", added, hit)
+	}
+	if trie.Contains(`world`) {
+		t.Error("trie should not contain 'world' after a rejected insert")
+	}
+	if trie.Size() != 6 {
+		t.Errorf("trie should still contain 6 nodes, has %d", trie.Size())
+	}
+
+	words := []string{`helping`, `hello`, `heap`, `hex`}
+	for _, w := range words {
+		trie.AddStringBounded(w, 8)
+		if trie.Size() > 8 {
+			t.Fatalf("trie size %d exceeds the budget after adding '%s'", trie.Size(), w)
+		}
+	}
+	if !trie.Contains(`heap`) || trie.Contains(`hex`) {
+		t.Errorf("expected 'heap' to be added and 'hex' to be rejected, got members %v", trie.Members())
+	}
+
+	// the nodes are counted after normalization
+	normalized := NewTrieNormalized(decomposer{})
+	if added, hit = normalized.AddStringBounded(`é`, 1); added || !hit || normalized.Size() != 0 {
+		t.Errorf("expected the decomposed 'é' to exceed a budget of 1 (added=%v, hitLimit=%v, size %d)", added, hit, normalized.Size())
+	}
+	if added, hit = normalized.AddStringBounded(`é`, 2); !added || hit || normalized.Size() != 2 {
+		t.Errorf("expected the decomposed 'é' to fit a budget of 2 (added=%v, hitLimit=%v, size %d)", added, hit, normalized.Size())
+	}
+
+	// strings longer than MaxDepth are not added, whatever the budget
+	deep := NewTrie()
+	deep.MaxDepth = 2
+	if added, hit = deep.AddStringBounded(`abc`, 10); added || hit || deep.Size() != 0 {
+		t.Errorf("expected 'abc' to be rejected by MaxDepth (added=%v, hitLimit=%v, size %d)", added, hit, deep.Size())
+	}
+}
+
+// decomposes 'é', standing in for norm.NFD
+type decomposer struct{}
+
+func (decomposer) String(s string) string {
+	return strings.ReplaceAll(s, "é", "e\u0301")
+}
+
+func TestKeysWithMinValue(t *testing.T) {
+	trie := NewTrie()
+	values := map[string]int{
+		`apple`:   10,
+		`apricot`: 3,
+		`banana`:  7,
+		`band`:    1,
+		`bandana`: 12,
+		`cherry`:  2,
+		`citrus`:  1,
+	}
+	for k, v := range values {
+		trie.AddValue(k, v)
+	}
+	trie.AddString(`date`) // no value at all
 
-	tok := s.Scan()
-	for tok != scanner.EOF {
-		switch tok {
-		case scanner.Ident:
-			// we handle two identifiers: 'patterns' and 'exceptions'
-			switch ident := s.TokenText(); ident {
-			case `patterns`, `exceptions`:
-				which = ident
-			default:
-				return nil, fmt.Errorf("Unrecognized identifier '%s' at position %v", ident, s.Pos())
+	for _, threshold := range []int{0, 2, 5, 10, 13} {
+		expected := []string{}
+		for k, v := range values {
+			if v >= threshold {
+				expected = append(expected, k)
 			}
-		case scanner.String, scanner.RawString:
-			// trim the quotes from around the string
-			tokstr := s.TokenText()
-			str := tokstr[1 : len(tokstr)-1]
-
-			switch which {
-			case `patterns`:
-				trie.AddPatternString(str)
+		}
+		sort.Strings(expected)
+
+		found := trie.KeysWithMinValue(threshold)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("threshold %d: expected %v but found %v", threshold, expected, found)
+		}
+	}
+
+	// the 'c' subtree holds nothing above 2, so it should not be descended into
+	visits := 0
+	trie.buildKeysWithMinValue(``, 5, &visits)
+	if visits >= trie.Size()+1 {
+		t.Errorf("expected subtrees to be pruned, but visited %d of %d nodes", visits, trie.Size()+1)
+	}
+	cherry := trie.children['c']
+	cVisits := 0
+	cherry.buildKeysWithMinValue(`c`, 5, &cVisits)
+	if cVisits != 1 {
+		t.Errorf("expected the 'c' subtree to be rejected at its root, visited %d nodes", cVisits)
+	}
+
+	// the bound is lowered by updates and removals, so pruning keeps working
+	trie.AddValue(`bandana`, 4)
+	trie.Remove(`apple`)
+	trie.RemoveByValue(7, nil)
+	if trie.maxValue != 4 || trie.children['b'].maxValue != 4 || trie.children['a'].maxValue != 3 {
+		t.Errorf("expected the bounds to fall to 4 and 3, found %d, %d and %d",
+			trie.maxValue, trie.children['b'].maxValue, trie.children['a'].maxValue)
+	}
+	visits = 0
+	if found := trie.buildKeysWithMinValue(``, 5, &visits); len(found) != 0 || visits != 1 {
+		t.Errorf("expected nothing to be found at the root, found %v after %d visits", found, visits)
+	}
+	trie.SetNodeValue(`band`, `one`)
+	trie.AppendInt(`bandana`, 1)
+	trie.RemovePrefixN(`ap`)
+	if trie.maxValue != 2 {
+		t.Errorf("expected the bound to fall to 2, found %d", trie.maxValue)
+	}
+	trie.Retain([]string{`d`})
+	if trie.maxValue != math.MinInt {
+		t.Errorf("expected no bound without int values, found %d", trie.maxValue)
+	}
+	checkMaxValue(t, trie)
+
+	// a node value counts once its node becomes a member, however it is added
+	trie = NewTrie()
+	trie.SetNodeValue(`ab`, 10)
+	trie.AddString(`ab`)
+	trie.SetNodeValue(`cd`, 20)
+	trie.AddRunes([]rune(`cd`))
+	trie.SetNodeValue(`ef`, 30)
+	trie.AddFromRuneReader(strings.NewReader(`ef`))
+	trie.SetNodeValue(`gh`, 40)
+	trie.AddStringBounded(`gh`, 100)
+	trie.SetNodeValue(`ij`, 50) // not a member, so it doesn't count
+	if found := trie.KeysWithMinValue(5); !reflect.DeepEqual(found, []string{`ab`, `cd`, `ef`, `gh`}) {
+		t.Errorf("expected [ab cd ef gh] with values of at least 5, found %v", found)
+	}
+	checkMaxValue(t, trie)
+}
+
+// checks the maxValue of every node against the values of the members below it
+func checkMaxValue(t *testing.T, p *Trie) int {
+	expected := math.MinInt
+	if v, ok := p.value.(int); ok && p.leaf {
+		expected = v
+	}
+	for _, child := range p.children {
+		expected = max(expected, checkMaxValue(t, child))
+	}
+	if p.maxValue != expected {
+		t.Errorf("expected a maxValue of %d but found %d", expected, p.maxValue)
+	}
+	return expected
+}
+
+// upperReader is a simple normalizing io.RuneReader which upper-cases ASCII runes.
+type upperReader struct {
+	runes []rune
+}
+
+func (u *upperReader) ReadRune() (rune, int, error) {
+	if len(u.runes) == 0 {
+		return 0, 0, io.EOF
+	}
+	r := u.runes[0]
+	u.runes = u.runes[1:]
+	if r >= 'a' && r <= 'z' {
+		r -= 'a' - 'A'
+	}
+	return r, utf8.RuneLen(r), nil
+}
+
+func TestRuneReader(t *testing.T) {
+	trie := NewTrie()
+
+	trie.AddFromRuneReader(&upperReader{[]rune(`hello`)})
+	trie.AddFromRuneReader(&upperReader{[]rune(`héllo`)})
+	trie.AddFromRuneReader(&upperReader{[]rune(``)})
+
+	if !trie.Contains(`HELLO`) || !trie.Contains(`HéLLO`) {
+		t.Errorf("trie should contain the normalized strings, has %v", trie.Members())
+	}
+	if trie.Contains(`hello`) {
+		t.Error("trie should not contain the un-normalized string 'hello'")
+	}
+	if len(trie.Members()) != 2 {
+		t.Errorf("trie should contain exactly two members, has %v", trie.Members())
+	}
+
+	if !trie.ContainsFromRuneReader(&upperReader{[]rune(`Hello`)}) {
+		t.Error("trie should contain 'Hello' read through the normalizing reader")
+	}
+	if trie.ContainsFromRuneReader(&upperReader{[]rune(`hell`)}) {
+		t.Error("trie should not contain the prefix 'hell'")
+	}
+	if trie.ContainsFromRuneReader(&upperReader{[]rune(``)}) {
+		t.Error("trie should not contain the empty string")
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a := NewTrie()
+	a.AddString(`hello`)
+	a.AddValue(`help`, []int32{1, 2, 3, 4})
+	a.AddValue(`world`, 42)
+
+	b := NewTrie()
+	b.AddValue(`world`, 42)
+	b.AddValue(`help`, []int32{1, 2, 3, 4})
+	b.AddString(`hello`)
+
+	if a.Fingerprint() != b.Fingerprint() {
+		t.Error("tries with identical contents should share a fingerprint")
+	}
+
+	b.Remove(`hello`)
+	b.AddString(`hellos`)
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("changing a key should change the fingerprint")
+	}
+
+	b.Remove(`hellos`)
+	b.AddString(`hello`)
+	b.AddValue(`world`, 43)
+	if a.Fingerprint() == b.Fingerprint() {
+		t.Error("changing a value should change the fingerprint")
+	}
+
+	if NewTrie().Fingerprint() == a.Fingerprint() {
+		t.Error("an empty trie should not share a fingerprint with a populated one")
+	}
+}
+
+func TestRemovePrefixN(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`app`)
+	trie.AddString(`apple`)
+	trie.AddString(`applet`)
+	trie.AddString(`apply`)
+	trie.AddString(`banana`)
+
+	// the 'appl' subtree holds 'apple', 'applet' & 'apply' in three nodes, plus
+	// the 'appl' node itself; 'app' is a member, so pruning stops there
+	keys, nodes := trie.RemovePrefixN(`appl`)
+	if keys != 3 || nodes != 4 {
+		t.Errorf("expected 3 keys and 4 nodes removed, got %d and %d", keys, nodes)
+	}
+	if !trie.Contains(`app`) || trie.Contains(`apple`) || !trie.Contains(`banana`) {
+		t.Errorf("unexpected members after removing 'appl': %v", trie.Members())
+	}
+	if trie.Size() != 9 {
+		t.Errorf("expected 9 nodes to remain, found %d", trie.Size())
+	}
+
+	// removing 'ban' also frees the now-empty 'b' and 'ba' nodes
+	keys, nodes = trie.RemovePrefixN(`ban`)
+	if keys != 1 || nodes != 6 {
+		t.Errorf("expected 1 key and 6 nodes removed, got %d and %d", keys, nodes)
+	}
+	if trie.Size() != 3 {
+		t.Errorf("expected 3 nodes to remain, found %d", trie.Size())
+	}
+
+	keys, nodes = trie.RemovePrefixN(`xyz`)
+	if keys != 0 || nodes != 0 {
+		t.Errorf("expected nothing removed for a missing prefix, got %d and %d", keys, nodes)
+	}
+
+	keys, nodes = trie.RemovePrefixN(``)
+	if keys != 1 || nodes != 3 || trie.Size() != 0 {
+		t.Errorf("expected 1 key and 3 nodes removed, got %d and %d", keys, nodes)
+	}
+
+	// internal nodes carrying a value stop the pruning, as they do for Remove
+	trie.SetNodeValue(`ab`, `mw`)
+	trie.AddString(`abc`)
+	keys, nodes = trie.RemovePrefixN(`abc`)
+	if keys != 1 || nodes != 1 {
+		t.Errorf("expected 1 key and 1 node removed, got %d and %d", keys, nodes)
+	}
+	if v, ok := trie.GetNodeValue(`ab`); !ok || v != `mw` {
+		t.Errorf("expected (mw, true) for the node 'ab', found (%v, %v)", v, ok)
+	}
+	checkSize(t, trie, `RemovePrefixN`)
+}
+
+func TestChildRunes(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`cat`)
+	trie.AddString(`car`)
+	trie.AddString(`cab`)
+	trie.AddString(`café`)
+	trie.AddString(`dog`)
+
+	runes, ok := trie.ChildRunes(`ca`)
+	expected := []rune{'b', 'f', 'r', 't'}
+	if !ok || !reflect.DeepEqual(runes, expected) {
+		t.Errorf("expected children %q of 'ca', found %q (%v)", expected, runes, ok)
+	}
+
+	runes, ok = trie.ChildRunes(``)
+	expected = []rune{'c', 'd'}
+	if !ok || !reflect.DeepEqual(runes, expected) {
+		t.Errorf("expected children %q of the root, found %q (%v)", expected, runes, ok)
+	}
+
+	runes, ok = trie.ChildRunes(`caf`)
+	expected = []rune{'é'}
+	if !ok || !reflect.DeepEqual(runes, expected) {
+		t.Errorf("expected children %q of 'caf', found %q (%v)", expected, runes, ok)
+	}
+
+	runes, ok = trie.ChildRunes(`cat`)
+	if !ok || len(runes) != 0 {
+		t.Errorf("expected no children of 'cat', found %q (%v)", runes, ok)
+	}
+
+	if _, ok = trie.ChildRunes(`cow`); ok {
+		t.Error("'cow' should not be a path in the trie")
+	}
+}
+
+func TestAppendSuffixToAll(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`a`, 1)
+	trie.AddValue(`ab`, 2)
+	trie.AddValue(`naïve`, []int32{1, 2})
+	trie.AddString(`plain`)
+
+	suffixed := trie.AppendSuffixToAll(`b`)
+	expected := []string{`ab`, `abb`, `naïveb`, `plainb`}
+	if !reflect.DeepEqual(suffixed.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, suffixed.Members())
+	}
+
+	for key, value := range map[string]interface{}{`ab`: 1, `abb`: 2, `naïveb`: []int32{1, 2}, `plainb`: nil} {
+		if v, ok := suffixed.GetValue(key); !ok || !reflect.DeepEqual(v, value) {
+			t.Errorf("expected value %v for '%s', found %v (%v)", value, key, v, ok)
+		}
+	}
+
+	// the original is unchanged
+	expected = []string{`a`, `ab`, `naïve`, `plain`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("original trie should still have members %v, found %v", expected, trie.Members())
+	}
+
+	same := trie.AppendSuffixToAll(``)
+	if !reflect.DeepEqual(same.Members(), expected) || same.Size() != trie.Size() {
+		t.Errorf("appending an empty suffix should copy the trie, found %v", same.Members())
+	}
+
+	// the copy keeps the trie's configuration and the values of internal nodes
+	folded := NewTrieFold()
+	folded.MaxDepth = 8
+	folded.AddValue(`Hello`, 1)
+	folded.SetNodeValue(`he`, `he`)
+	folded.AddExceptionString(`hel-lo`)
+	copied := folded.AppendSuffixToAll(`X`)
+	if !copied.Contains(`HELLOx`) || copied.MaxDepth != 8 {
+		t.Errorf("expected a case-insensitive copy with a MaxDepth of 8, found %v and %d", copied.Members(), copied.MaxDepth)
+	}
+	if v, ok := copied.GetNodeValue(`he`); !ok || v != `he` {
+		t.Errorf("expected (he, true) for the node 'he', found (%v, %v)", v, ok)
+	}
+	if !reflect.DeepEqual(copied.Hyphenate(`hello`), []string{`hel`, `lo`}) {
+		t.Errorf("expected the exceptions to be copied, found %v", copied.Hyphenate(`hello`))
+	}
+	checkMaxValue(t, copied)
+}
+
+func TestKeyAtDFS(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`dog`, `cat`, `car`, `ca`, `cart`, `éclair`, `do`} {
+		trie.AddString(s)
+	}
+
+	expected := []string{`ca`, `car`, `cart`, `cat`, `do`, `dog`, `éclair`}
+	for run := 0; run < 3; run++ {
+		for i, e := range expected {
+			if key, ok := trie.KeyAtDFS(i); !ok || key != e {
+				t.Errorf("expected key %d to be '%s', found '%s' (%v)", i, e, key, ok)
 			}
 		}
-		tok = s.Scan()
 	}
 
-	return trie, nil
+	if _, ok := trie.KeyAtDFS(len(expected)); ok {
+		t.Error("there should be no key beyond the last member")
+	}
+	if _, ok := trie.KeyAtDFS(-1); ok {
+		t.Error("there should be no key at a negative ordinal")
+	}
 }
 
-var benchmarkTrie *Trie
+func TestUniquePrefixes(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`cat`)
+	trie.AddString(`car`)
+	trie.AddString(`dog`)
 
-func setupTrie() *Trie {
-	/*
-		filename := "patterns-en.go"
-		f, err := os.Open(filename, 0444, os.O_RDONLY)
-		if err != nil {
-			fmt.Printf("Failed to open file '%s': %s\n", filename, err)
+	expected := map[string]string{`cat`: `cat`, `car`: `car`, `dog`: `d`}
+	if found := trie.UniquePrefixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	trie.AddString(`do`)
+	trie.AddString(`cartwheel`)
+	trie.AddString(`épée`)
+	expected = map[string]string{
+		`cat`: `cat`, `car`: `car`, `cartwheel`: `cart`, `do`: `do`, `dog`: `dog`, `épée`: `é`,
+	}
+	if found := trie.UniquePrefixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	if found := NewTrie().UniquePrefixes(); len(found) != 0 {
+		t.Errorf("an empty trie should have no unique prefixes, found %v", found)
+	}
+}
+
+func TestOrderedIteration(t *testing.T) {
+	trie := NewTrie()
+	if _, _, ok := trie.First(); ok {
+		t.Error("an empty trie should have no first member")
+	}
+	if _, _, ok := trie.Last(); ok {
+		t.Error("an empty trie should have no last member")
+	}
+
+	words := []string{`car`, `ca`, `cart`, `cat`, `do`, `dog`, `éclair`}
+	for i, w := range words {
+		trie.AddValue(w, i)
+	}
+
+	expected := trie.Members()
+	found := []string{}
+	key, value, ok := trie.First()
+	for ok {
+		found = append(found, key)
+		if v, _ := trie.GetValue(key); v != value {
+			t.Errorf("expected value %v for '%s', found %v", v, key, value)
 		}
-	*/
-	if benchmarkTrie == nil {
-		var err error
-		benchmarkTrie, err = loadPatterns(os.Stdin)
-		if err != nil {
-			fmt.Printf("Failed to load patterns from Stdin: %s\n", err)
+		key, value, ok = trie.Next(key)
+	}
+	if !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected iteration order %v, found %v", expected, found)
+	}
+
+	if key, _, _ := trie.Last(); key != `éclair` {
+		t.Errorf("expected the last member to be 'éclair', found '%s'", key)
+	}
+
+	// keys need not be members
+	tests := map[string]string{``: `ca`, `c`: `ca`, `cas`: `cat`, `cb`: `do`, `d`: `do`, `dogs`: `éclair`}
+	for k, e := range tests {
+		if n, _, ok := trie.Next(k); !ok || n != e {
+			t.Errorf("expected the member following '%s' to be '%s', found '%s' (%v)", k, e, n, ok)
 		}
 	}
-	return benchmarkTrie
+	if n, _, ok := trie.Next(`éclairs`); ok {
+		t.Errorf("expected no member following 'éclairs', found '%s'", n)
+	}
 }
 
-func BenchmarkTraversal(b *testing.B) {
-	b.StopTimer()
-	trie := setupTrie()
-	if trie == nil {
-		return
+func TestAllSubstringsN(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`h`)
+	trie.AddString(`hy`)
+	trie.AddString(`hyp`)
+	trie.AddString(`hyph`)
+	trie.AddString(`hyphé`)
+
+	tests := []struct {
+		n        int
+		expected []string
+	}{
+		{1, []string{`h`}},
+		{3, []string{`h`, `hy`, `hyp`}},
+		{10, []string{`h`, `hy`, `hyp`, `hyph`, `hyphé`}},
+		{0, []string{`h`, `hy`, `hyp`, `hyph`, `hyphé`}},
 	}
-	b.StartTimer()
+	for _, test := range tests {
+		found := trie.AllSubstringsN(`hyphénation`, test.n)
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("n=%d: expected %v but found %v", test.n, test.expected, found)
+		}
+	}
+}
 
-	for i := 0; i < b.N; i++ {
-		trie.Members()
+func TestNodeValues(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`/api/users`, `users`)
+
+	if !trie.SetNodeValue(`/api`, `auth`) {
+		t.Fatal("setting a value on '/api' should succeed")
+	}
+	if trie.Contains(`/api`) {
+		t.Error("setting a node value should not make '/api' a member")
+	}
+	if v, ok := trie.GetNodeValue(`/api`); !ok || v != `auth` {
+		t.Errorf("expected node value 'auth' for '/api', found %v (%v)", v, ok)
+	}
+	if v, ok := trie.GetNodeValue(`/api/users`); !ok || v != `users` {
+		t.Errorf("expected node value 'users' for '/api/users', found %v (%v)", v, ok)
+	}
+
+	// paths are created as needed, again without adding members
+	if !trie.SetNodeValue(`/static`, `cache`) {
+		t.Fatal("setting a value on '/static' should succeed")
+	}
+	if v, ok := trie.GetNodeValue(`/static`); !ok || v != `cache` {
+		t.Errorf("expected node value 'cache' for '/static', found %v (%v)", v, ok)
+	}
+	if !reflect.DeepEqual(trie.Members(), []string{`/api/users`}) {
+		t.Errorf("expected only '/api/users' as a member, found %v", trie.Members())
+	}
+
+	// removing a member below a node with a value keeps that node
+	trie.Remove(`/api/users`)
+	if v, ok := trie.GetNodeValue(`/api`); !ok || v != `auth` {
+		t.Errorf("expected node value 'auth' for '/api' to survive removal, found %v (%v)", v, ok)
+	}
+
+	// removing a string which isn't a member leaves the value of its node alone
+	trie.Remove(`/api`)
+	trie.Remove(`/static`)
+	if v, ok := trie.GetNodeValue(`/api`); !ok || v != `auth` {
+		t.Errorf("expected node value 'auth' for '/api' to survive removing it, found %v (%v)", v, ok)
+	}
+	if v, ok := trie.GetNodeValue(`/static`); !ok || v != `cache` {
+		t.Errorf("expected node value 'cache' for '/static' to survive removing it, found %v (%v)", v, ok)
+	}
+	checkSize(t, trie, `Remove`)
+
+	if _, ok := trie.GetNodeValue(`/nothing`); ok {
+		t.Error("'/nothing' should not be a path in the trie")
+	}
+	if trie.SetNodeValue(``, 1) {
+		t.Error("setting a value for the empty string should fail")
+	}
+}
+
+func TestAllNodePrefixes(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+
+	expected := []string{`a`, `ab`, `abc`}
+	if found := trie.AllNodePrefixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	trie.AddString(`bé`)
+	expected = []string{`a`, `ab`, `abc`, `b`, `bé`}
+	if found := trie.AllNodePrefixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+	if len(trie.AllNodePrefixes()) != trie.Size() {
+		t.Error("there should be one prefix for every node in the trie")
+	}
+}
+
+func TestGetValueTyped(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`hyphenation`, []int32{0, 3, 0, 0, 2, 5, 4, 2, 0, 2, 0})
+	trie.AddString(`plain`)
+
+	var values []int32
+	if err := trie.GetValueTyped(`hyphenation`, &values); err != nil {
+		t.Errorf("reading a []int32 value should succeed, got error: %s", err)
+	}
+	if len(values) != 11 || values[1] != 3 {
+		t.Errorf("unexpected value %v", values)
+	}
+
+	var str string
+	if err := trie.GetValueTyped(`hyphenation`, &str); err == nil {
+		t.Error("reading a []int32 value as a string should fail")
+	}
+
+	var any interface{}
+	if err := trie.GetValueTyped(`hyphenation`, &any); err != nil {
+		t.Errorf("reading a value into an interface{} should succeed, got error: %s", err)
+	}
+
+	if err := trie.GetValueTyped(`missing`, &values); err == nil {
+		t.Error("reading the value of a missing string should fail")
+	}
+	if err := trie.GetValueTyped(`hyphenation`, values); err == nil {
+		t.Error("reading a value into a non-pointer should fail")
+	}
+
+	// nil values can be read into nillable types only
+	values = []int32{1}
+	if err := trie.GetValueTyped(`plain`, &values); err != nil || values != nil {
+		t.Errorf("reading a nil value into a slice should succeed, got %v (%v)", values, err)
+	}
+	if err := trie.GetValueTyped(`plain`, &str); err == nil {
+		t.Error("reading a nil value as a string should fail")
+	}
+}
+
+func TestPrefixRange(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`a`)
+	trie.AddString(`ab`)
+	trie.AddString(`abc`)
+	trie.AddString(`abcdé`)
+	trie.AddString(`bc`)
+
+	tests := []struct {
+		s, shortest, longest string
+		ok                   bool
+	}{
+		{`abcdéf`, `a`, `abcdé`, true},
+		{`abcd`, `a`, `abc`, true},
+		{`a`, `a`, `a`, true},
+		{`bcd`, `bc`, `bc`, true},
+		{`b`, ``, ``, false},
+		{`xyz`, ``, ``, false},
+	}
+	for _, test := range tests {
+		shortest, longest, ok := trie.PrefixRange(test.s)
+		if shortest != test.shortest || longest != test.longest || ok != test.ok {
+			t.Errorf("'%s': expected ('%s', '%s', %v), found ('%s', '%s', %v)", test.s,
+				test.shortest, test.longest, test.ok, shortest, longest, ok)
+		}
+	}
+}
+
+func TestRemovePrefixExcept(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`app`, `apple`, `applet`, `apply`, `apt`, `banana`} {
+		trie.AddValue(s, len(s))
+	}
+
+	if removed := trie.RemovePrefixExcept(`app`, `apple`); removed != 3 {
+		t.Errorf("expected 3 members removed, found %d", removed)
+	}
+	expected := []string{`apple`, `apt`, `banana`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, trie.Members())
+	}
+	if v, _ := trie.GetValue(`apple`); v != 5 {
+		t.Errorf("expected 'apple' to keep its value, found %v", v)
+	}
+	if trie.Size() != 12 {
+		t.Errorf("expected 12 nodes to remain, found %d", trie.Size())
+	}
+
+	// keep is not under the prefix, so everything under it goes
+	if removed := trie.RemovePrefixExcept(`b`, `apple`); removed != 1 {
+		t.Errorf("expected 1 member removed, found %d", removed)
+	}
+	expected = []string{`apple`, `apt`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, trie.Members())
+	}
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	row := make([]int, len(rb)+1)
+	for j := range row {
+		row[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		prev := row[0]
+		row[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur := min(row[j]+1, row[j-1]+1, prev+cost)
+			prev, row[j] = row[j], cur
+		}
+	}
+	return row[len(rb)]
+}
+
+func TestNearestDistance(t *testing.T) {
+	trie := NewTrie()
+	words := []string{`hyphenation`, `hyphen`, `trie`, `tree`, `naïve`, `concatenation`}
+	for _, w := range words {
+		trie.AddString(w)
+	}
+
+	queries := []string{`hyphenation`, `hyphenate`, `tre`, `try`, `naive`, `xyzzy`, ``, `concatenate`}
+	for _, q := range queries {
+		for _, maxDist := range []int{1, 3, 20} {
+			expected := maxDist + 1
+			for _, w := range words {
+				expected = min(expected, levenshtein(q, w))
+			}
+			if found := trie.NearestDistance(q, maxDist); found != expected {
+				t.Errorf("'%s' (max %d): expected distance %d, found %d", q, maxDist, expected, found)
+			}
+		}
+	}
+
+	if found := NewTrie().NearestDistance(`anything`, 2); found != 3 {
+		t.Errorf("an empty trie should report maxDist+1, found %d", found)
+	}
+}
+
+func TestMembersByInsertionOrder(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`pear`)
+	trie.AddValue(`apple`, 1)
+	trie.AddString(`peach`)
+	trie.AddString(`banana`)
+	trie.AddString(`pear`) // re-adding doesn't move it
+	trie.AddValue(`apple`, 2)
+	trie.Remove(`peach`)
+	trie.AddString(`cherry`)
+	trie.AddString(`peach`) // removed and re-added, so it moves to the end
+	trie.AddPatternString(`a1b`)
+
+	expected := []string{`pear`, `apple`, `banana`, `cherry`, `peach`, `ab`}
+	if found := trie.MembersByInsertionOrder(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected insertion order %v, found %v", expected, found)
+	}
+
+	// copies keep the original order
+	suffixed := trie.AppendSuffixToAll(`s`)
+	suffixed.AddString(`dates`)
+	expected = []string{`pears`, `apples`, `bananas`, `cherrys`, `peachs`, `abs`, `dates`}
+	if found := suffixed.MembersByInsertionOrder(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected insertion order %v, found %v", expected, found)
+	}
+}
+
+func TestBestUnderPrefix(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`car`, 40)
+	trie.AddValue(`card`, 25)
+	trie.AddValue(`care`, 90)
+	trie.AddValue(`career`, 90)
+	trie.AddValue(`cat`, 100)
+	trie.AddString(`carp`)
+
+	tests := []struct {
+		prefix, key string
+		value       int
+		ok          bool
+	}{
+		{`car`, `care`, 90, true},
+		{`ca`, `cat`, 100, true},
+		{`card`, `card`, 25, true},
+		{`carp`, ``, 0, false},
+		{`dog`, ``, 0, false},
+	}
+	for _, test := range tests {
+		key, value, ok := trie.BestUnderPrefix(test.prefix)
+		if key != test.key || ok != test.ok || (ok && value != test.value) {
+			t.Errorf("'%s': expected ('%s', %v, %v), found ('%s', %v, %v)", test.prefix,
+				test.key, test.value, test.ok, key, value, ok)
+		}
+	}
+}
+
+func TestAppendInt(t *testing.T) {
+	trie := NewTrie()
+	trie.AppendInt(`trie`, 3)
+	trie.AppendInt(`tree`, 1)
+	trie.AppendInt(`trie`, 1)
+	trie.AppendInt(`trie`, 4)
+	trie.AppendInt(`trie`, 1)
+
+	if ids, ok := trie.Ints(`trie`); !ok || !reflect.DeepEqual(ids, []int{3, 1, 4, 1}) {
+		t.Errorf("expected postings [3 1 4 1] for 'trie', found %v (%v)", ids, ok)
+	}
+	if ids, ok := trie.Ints(`tree`); !ok || !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("expected postings [1] for 'tree', found %v (%v)", ids, ok)
+	}
+	if _, ok := trie.Ints(`tri`); ok {
+		t.Error("'tri' should have no postings")
+	}
+
+	trie.AddValue(`other`, `not ints`)
+	if _, ok := trie.Ints(`other`); ok {
+		t.Error("'other' does not have an []int value")
+	}
+}
+
+func TestIsPrefixFree(t *testing.T) {
+	trie := NewTrie()
+	if !trie.IsPrefixFree() {
+		t.Error("an empty trie should be prefix-free")
+	}
+
+	trie.AddString(`a`)
+	trie.AddString(`b`)
+	trie.AddString(`c`)
+	if !trie.IsPrefixFree() {
+		t.Error("{a, b, c} should be prefix-free")
+	}
+
+	trie.AddString(`ab`)
+	if trie.IsPrefixFree() {
+		t.Error("{a, ab, b, c} should not be prefix-free")
+	}
+
+	trie.Remove(`a`)
+	trie.SetNodeValue(`bcd`, 1) // not a member, so it doesn't count
+	if !trie.IsPrefixFree() {
+		t.Error("{ab, b, c} should be prefix-free")
+	}
+}
+
+func TestAllSubstringsFull(t *testing.T) {
+	trie := NewTrie()
+	trie.AddPatternString(`hy3ph`)
+	trie.AddPatternString(`he2n`)
+	trie.AddPatternString(`hena4`)
+	trie.AddPatternString(`hen5at`)
+	trie.AddPatternString(`5hé`)
+
+	for _, s := range []string{`hyphenation`, `henation`, `héllo`, `xyz`} {
+		strs, values := trie.AllSubstringsAndValues(s)
+		full := trie.AllSubstringsFull(s)
+		if len(full) != len(strs) {
+			t.Fatalf("'%s': expected %d matches, found %v", s, len(strs), full)
+		}
+		for i, m := range full {
+			if m.Str != strs[i] || m.RuneLen != utf8.RuneCountInString(strs[i]) || !reflect.DeepEqual(m.Value, values[i]) {
+				t.Errorf("'%s': expected match (%s, %v), found %v", s, strs[i], values[i], m)
+			}
+		}
+	}
+}
+
+func TestInternValues(t *testing.T) {
+	trie := NewTrie()
+	words := []string{`ab`, `abc`, `bcd`, `cde`, `def`}
+	for _, w := range words {
+		trie.AddValue(w, []int32{0, 1, 0})
+	}
+	trie.AddValue(`other`, []int32{2})
+	trie.AddString(`novalue`)
+
+	n := trie.InternValues(func(a, b interface{}) bool { return reflect.DeepEqual(a, b) })
+	if n != len(words)-1 {
+		t.Errorf("expected %d values to be collapsed, found %d", len(words)-1, n)
+	}
+
+	first, _ := trie.GetValue(words[0])
+	shared := &first.([]int32)[0]
+	for _, w := range words[1:] {
+		v, _ := trie.GetValue(w)
+		if &v.([]int32)[0] != shared {
+			t.Errorf("expected the value of '%s' to be shared", w)
+		}
+	}
+	if v, _ := trie.GetValue(`other`); &v.([]int32)[0] == shared {
+		t.Error("the distinct value of 'other' should not be shared")
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	trie := NewTrie()
+	trie.MaxDepth = 5
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+
+	size := trie.Size()
+	if err := trie.TryAddString(`helpful`); err != ErrMaxDepth {
+		t.Errorf("adding a string longer than MaxDepth should fail with ErrMaxDepth, got %v", err)
+	}
+	if trie.Contains(`helpful`) || trie.Size() != size {
+		t.Errorf("a rejected string should not be partially inserted (size %d, was %d)", trie.Size(), size)
+	}
+
+	if err := trie.TryAddValue(`worldwide`, 1); err != ErrMaxDepth {
+		t.Errorf("adding a string longer than MaxDepth should fail with ErrMaxDepth, got %v", err)
+	}
+	trie.AddString(`worldwide`)
+	trie.AddFromRuneReader(strings.NewReader(`helper`))
+	if trie.Size() != size {
+		t.Errorf("rejected strings should not be partially inserted (size %d, was %d)", trie.Size(), size)
+	}
+
+	if err := trie.TryAddValue(`héllo`, 1); err != nil {
+		t.Errorf("depth is counted in runes, so 'héllo' should be accepted, got %v", err)
+	}
+
+	trie.MaxDepth = 0
+	if err := trie.TryAddString(`helpful`); err != nil || !trie.Contains(`helpful`) {
+		t.Errorf("a MaxDepth of zero should impose no limit, got %v", err)
+	}
+}
+
+func TestLongestPrefixMatch(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`/`, `root`)
+	trie.AddValue(`/api`, `api`)
+	trie.AddValue(`/api/users`, `users`)
+	trie.AddValue(`/café`, `café`)
+
+	tests := []struct {
+		s, key string
+		value  interface{}
+		ok     bool
+	}{
+		{`/api/users/42`, `/api/users`, `users`, true},
+		{`/api/user`, `/api`, `api`, true},
+		{`/api`, `/api`, `api`, true},
+		{`/cafés`, `/café`, `café`, true},
+		{`/caf`, `/`, `root`, true},
+		{`api`, ``, nil, false},
+		{``, ``, nil, false},
+	}
+	for _, test := range tests {
+		key, value, ok := trie.LongestPrefixMatch(test.s)
+		if key != test.key || value != test.value || ok != test.ok {
+			t.Errorf("'%s': expected ('%s', %v, %v), found ('%s', %v, %v)", test.s,
+				test.key, test.value, test.ok, key, value, ok)
+		}
+	}
+}
+
+func TestPrefixSearch(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`car`, `card`, `care`, `careful`, `cat`, `dog`, `caña`} {
+		trie.AddString(s)
+	}
+
+	tests := map[string][]string{
+		`car`:  {`car`, `card`, `care`, `careful`},
+		`care`: {`care`, `careful`},
+		`ca`:   {`car`, `card`, `care`, `careful`, `cat`, `caña`},
+		`cañ`:  {`caña`},
+		`d`:    {`dog`},
+		`cow`:  {},
+		`cart`: {},
+		``:     trie.Members(),
+	}
+	for prefix, expected := range tests {
+		if found := trie.PrefixSearch(prefix); !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected %v but found %v", prefix, expected, found)
+		}
+	}
+}
+
+func TestBatchLongestPrefix(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`a`, `ab`, `abc`, `b`, `bcd`, `über`} {
+		trie.AddString(s)
+	}
+
+	queries := []string{`abcd`, `abx`, `bc`, `bcde`, `überall`, `xyz`, ``}
+	found := trie.BatchLongestPrefix(queries)
+	if len(found) != len(queries) {
+		t.Fatalf("expected %d results, found %v", len(queries), found)
+	}
+	for i, q := range queries {
+		expected, _, _ := trie.LongestPrefixMatch(q)
+		if found[i] != expected {
+			t.Errorf("'%s': expected '%s' but found '%s'", q, expected, found[i])
+		}
+	}
+}
+
+func TestPrefixSearchN(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`car`, `card`, `care`, `careful`, `cat`, `dog`, `caña`} {
+		trie.AddString(s)
+	}
+
+	for _, prefix := range []string{`ca`, `car`, `dog`, `cow`, ``} {
+		all := trie.PrefixSearch(prefix)
+		for limit := -1; limit <= len(all)+1; limit++ {
+			expected := all
+			if limit > 0 && limit < len(all) {
+				expected = all[:limit]
+			}
+			if found := trie.PrefixSearchN(prefix, limit); !reflect.DeepEqual(found, expected) {
+				t.Errorf("'%s' (limit %d): expected %v but found %v", prefix, limit, expected, found)
+			}
+		}
+	}
+}
+
+func TestWalk(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`ab`, 1)
+	trie.AddValue(`abc`, 2)
+	trie.AddValue(`bé`, 3)
+
+	keys := []string{}
+	leaves := []string{}
+	trie.Walk(func(key string, value interface{}, isLeaf bool) bool {
+		keys = append(keys, key)
+		if isLeaf {
+			leaves = append(leaves, fmt.Sprintf("%s=%v", key, value))
+		}
+		return true
+	})
+	if expected := []string{`a`, `ab`, `abc`, `b`, `bé`}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected to visit %v, visited %v", expected, keys)
+	}
+	if expected := []string{`ab=1`, `abc=2`, `bé=3`}; !reflect.DeepEqual(leaves, expected) {
+		t.Errorf("expected leaves %v, found %v", expected, leaves)
+	}
+
+	// prune everything below 'a'
+	keys = []string{}
+	trie.Walk(func(key string, value interface{}, isLeaf bool) bool {
+		keys = append(keys, key)
+		return key != `a`
+	})
+	if expected := []string{`a`, `b`, `bé`}; !reflect.DeepEqual(keys, expected) {
+		t.Errorf("expected to visit %v, visited %v", expected, keys)
+	}
+}
+
+func TestKeyFromPath(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`car`, `cart`, `cat`, `dog`, `dög`} {
+		trie.AddString(s)
+	}
+
+	for _, member := range trie.Members() {
+		// encode the member as the index of each rune among its siblings
+		path := []int{}
+		for pos, r := range member {
+			runes, _ := trie.ChildRunes(member[:pos])
+			for i, c := range runes {
+				if c == r {
+					path = append(path, i)
+				}
+			}
+		}
+
+		if key, ok := trie.KeyFromPath(path); !ok || key != member {
+			t.Errorf("expected path %v to give '%s', found '%s' (%v)", path, member, key, ok)
+		}
+	}
+
+	if key, ok := trie.KeyFromPath([]int{0, 0}); ok {
+		t.Errorf("'%s' is not a member, so its path should not be valid", key)
+	}
+	if _, ok := trie.KeyFromPath([]int{0, 5}); ok {
+		t.Error("a path with an out-of-range index should not be valid")
+	}
+	if _, ok := trie.KeyFromPath(nil); ok {
+		t.Error("an empty path should not be valid")
+	}
+}
+
+func TestRemoveByValue(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`session/a`, []int32{1})
+	trie.AddValue(`session/b`, []int32{2})
+	trie.AddValue(`session/c`, []int32{1})
+	trie.AddValue(`cache`, []int32{1})
+	trie.AddValue(`cached`, []int32{3})
+	trie.AddString(`plain`)
+
+	if n := trie.RemoveByValue([]int32{1}, nil); n != 3 {
+		t.Errorf("expected 3 members removed, found %d", n)
+	}
+	expected := []string{`cached`, `plain`, `session/b`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, trie.Members())
+	}
+	if trie.Size() != len(`cached`)+len(`plain`)+len(`session/b`) {
+		t.Errorf("expected empty branches to be pruned, but the trie has %d nodes", trie.Size())
+	}
+
+	// a custom comparison
+	n := trie.RemoveByValue(2, func(a, b interface{}) bool {
+		v, ok := a.([]int32)
+		return ok && len(v) == 1 && int(v[0]) >= b.(int)
+	})
+	if n != 2 || !reflect.DeepEqual(trie.Members(), []string{`plain`}) {
+		t.Errorf("expected 2 members removed, leaving 'plain', found %d and %v", n, trie.Members())
+	}
+}
+
+func TestMembersBefore(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`a`, `ab`, `abc`, `b`, `ba`, `bb`, `c`, `ça`} {
+		trie.AddString(s)
+	}
+
+	tests := []struct {
+		cursor   string
+		limit    int
+		expected []string
+	}{
+		{`bb`, 3, []string{`abc`, `b`, `ba`}},
+		{`bab`, 2, []string{`b`, `ba`}},
+		{`b`, 10, []string{`a`, `ab`, `abc`}},
+		{`b`, 0, []string{`a`, `ab`, `abc`}},
+		{`zzz`, 2, []string{`bb`, `c`}},
+		{`ğ`, 1, []string{`ça`}},
+		{`a`, 5, []string{}},
+		{``, 5, []string{}},
+	}
+	for _, test := range tests {
+		if found := trie.MembersBefore(test.cursor, test.limit); !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("'%s' (limit %d): expected %v but found %v", test.cursor, test.limit, test.expected, found)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a := NewTrie()
+	a.AddValue(`hello`, 1)
+	a.AddValue(`help`, 2)
+	a.AddString(`world`)
+
+	b := NewTrie()
+	b.AddValue(`help`, 20)
+	b.AddValue(`helper`, 30)
+	b.AddString(`hero`)
+
+	a.Merge(b)
+
+	expected := []string{`hello`, `help`, `helper`, `hero`, `world`}
+	if !reflect.DeepEqual(a.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, a.Members())
+	}
+	for key, value := range map[string]interface{}{`hello`: 1, `help`: 20, `helper`: 30, `hero`: nil} {
+		if v, _ := a.GetValue(key); v != value {
+			t.Errorf("expected value %v for '%s', found %v", value, key, v)
+		}
+	}
+
+	// h-e-l-l-o, p-e-r, r-o, w-o-r-l-d: shared prefixes are counted once
+	if a.Size() != 15 {
+		t.Errorf("expected 15 nodes in the union, found %d", a.Size())
+	}
+	if len(b.Members()) != 3 || b.Size() != 8 {
+		t.Errorf("the merged trie should be unchanged, found %v", b.Members())
+	}
+	if keys := a.KeysWithMinValue(25); !reflect.DeepEqual(keys, []string{`helper`}) {
+		t.Errorf("expected merged values to be found by KeysWithMinValue, found %v", keys)
+	}
+}
+
+func TestCount(t *testing.T) {
+	trie := NewTrie()
+	if trie.Count() != 0 {
+		t.Errorf("expected an empty trie to count 0, found %d", trie.Count())
+	}
+
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+	trie.AddString(`hello, world!`)
+	if trie.Count() != 3 {
+		t.Errorf("expected 3 members, found %d", trie.Count())
+	}
+
+	// adding a member again doesn't change the count
+	trie.AddString(`help`)
+	if trie.Count() != 3 {
+		t.Errorf("expected 3 members after a duplicate add, found %d", trie.Count())
+	}
+
+	trie.Remove(`hello`)
+	if trie.Count() != 2 {
+		t.Errorf("expected 2 members after removing one, found %d", trie.Count())
+	}
+	trie.Remove(`nothing`)
+	if trie.Count() != 2 {
+		t.Errorf("expected 2 members after removing a non-member, found %d", trie.Count())
+	}
+}
+
+func TestRemoveInternalMember(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`hello`, 1)
+	trie.AddValue(`hello, world!`, 2)
+
+	trie.Remove(`hello`)
+	if v, ok := trie.GetValue(`hello`); ok || v != nil {
+		t.Errorf("expected (nil, false) for a removed prefix, found (%v, %v)", v, ok)
+	}
+	if trie.Contains(`hello`) {
+		t.Error("removed prefix 'hello' is still a member")
+	}
+	if v, ok := trie.GetValue(`hello, world!`); !ok || v != 2 {
+		t.Errorf("expected (2, true) for 'hello, world!', found (%v, %v)", v, ok)
+	}
+	if trie.Size() != 13 {
+		t.Errorf("expected the path to 'hello, world!' to be kept, found %d nodes", trie.Size())
+	}
+}
+
+func TestRemoveKeepsPrefixMember(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`hyphen1`, 1)
+	trie.AddValue(`hyphen10`, 10)
+
+	trie.Remove(`hyphen10`)
+	if v, ok := trie.GetValue(`hyphen1`); !ok || v != 1 {
+		t.Errorf("expected (1, true) for 'hyphen1', found (%v, %v)", v, ok)
+	}
+	if trie.Contains(`hyphen10`) {
+		t.Error("removed string 'hyphen10' is still a member")
+	}
+	if trie.Size() != 7 {
+		t.Errorf("expected only the final node to be pruned, found %d nodes", trie.Size())
+	}
+
+	if !trie.Remove(`hyphen1`) {
+		t.Error("expected the trie to be empty after removing its last member")
+	}
+}
+
+func TestContainsPrefix(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+
+	for _, s := range []string{``, `h`, `hel`, `hell`, `hello`, `help`} {
+		if !trie.ContainsPrefix(s) {
+			t.Errorf("expected '%s' to be a prefix", s)
+		}
+	}
+	for _, s := range []string{`x`, `hex`, `hello!`, `helps`} {
+		if trie.ContainsPrefix(s) {
+			t.Errorf("expected '%s' not to be a prefix", s)
+		}
+	}
+
+	if trie.Contains(`hel`) {
+		t.Error("'hel' is a prefix but should not be a member")
+	}
+}
+
+func TestAllSubstringsWithValues(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`h`, 1)
+	trie.AddString(`hy`)
+	trie.AddValue(`hyp`, 3)
+	trie.AddString(`hyph`)
+	trie.AddValue(`hyphen`, 6)
+
+	found, values := trie.AllSubstringsWithValues(`hyphenation`)
+	expectedFound := []string{`h`, `hyp`, `hyphen`}
+	expectedValues := []interface{}{1, 3, 6}
+	if !reflect.DeepEqual(found, expectedFound) {
+		t.Errorf("expected substrings %v, found %v", expectedFound, found)
+	}
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("expected values %v, found %v", expectedValues, values)
+	}
+
+	// the unfiltered version still includes members without values
+	found, _ = trie.AllSubstringsAndValues(`hyphenation`)
+	if len(found) != 5 {
+		t.Errorf("expected 5 unfiltered substrings, found %v", found)
+	}
+}
+
+func TestRefCount(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`hello`)
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+
+	if trie.RefCount(`hello`) != 2 || trie.RefCount(`help`) != 1 || trie.RefCount(`hel`) != 0 {
+		t.Errorf("expected reference counts 2, 1, 0, found %d, %d, %d",
+			trie.RefCount(`hello`), trie.RefCount(`help`), trie.RefCount(`hel`))
+	}
+
+	// added twice, removed once
+	trie.Remove(`hello`)
+	if !trie.Contains(`hello`) || trie.RefCount(`hello`) != 1 {
+		t.Errorf("expected 'hello' to remain with a count of 1, found %d", trie.RefCount(`hello`))
+	}
+	trie.Remove(`hello`)
+	if trie.Contains(`hello`) || trie.RefCount(`hello`) != 0 {
+		t.Error("expected 'hello' to be removed after the second removal")
+	}
+
+	// values don't add references
+	trie.AddValue(`world`, 1)
+	trie.AddValue(`world`, 2)
+	if trie.RefCount(`world`) != 1 {
+		t.Errorf("expected AddValue to leave a count of 1, found %d", trie.RefCount(`world`))
+	}
+	if trie.Remove(`world`); trie.Contains(`world`) {
+		t.Error("expected a single removal to remove 'world'")
+	}
+
+	// re-adding a removed string starts counting again
+	trie.AddString(`hello`)
+	if trie.RefCount(`hello`) != 1 {
+		t.Errorf("expected a re-added string to have a count of 1, found %d", trie.RefCount(`hello`))
+	}
+}
+
+func TestMatchWildcard(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`cat`, `cot`, `cut`, `cart`, `ca`, `caté`, `dog`} {
+		trie.AddString(s)
+	}
+
+	tests := map[string][]string{
+		`c?t`:  {`cat`, `cot`, `cut`},
+		`ca?`:  {`cat`},
+		`ca??`: {`cart`, `caté`},
+		`???`:  {`cat`, `cot`, `cut`, `dog`},
+		`cat?`: {`caté`},
+		`ca`:   {`ca`},
+		`x?`:   {},
+		`d?g?`: {},
+	}
+	for pattern, expected := range tests {
+		found := trie.MatchWildcard(pattern)
+		if !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected %v but found %v", pattern, expected, found)
+		}
+	}
+}
+
+func TestNode(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`hello`, 1)
+	trie.AddValue(`help`, 2)
+	trie.AddString(`world`)
+
+	n, ok := trie.Node(`hel`)
+	if !ok {
+		t.Fatal("expected a node for the prefix 'hel'")
+	}
+	members := n.Members()
+	sort.Strings(members)
+	if !reflect.DeepEqual(members, []string{`lo`, `p`}) {
+		t.Errorf("expected members relative to 'hel', found %v", members)
+	}
+	if v, ok := n.GetValue(`p`); !ok || v != 2 {
+		t.Errorf("expected (2, true) for 'p' below 'hel', found (%v, %v)", v, ok)
+	}
+
+	if _, ok := trie.Node(`help`); !ok {
+		t.Error("expected a node for the member 'help'")
+	}
+	if _, ok := trie.Node(`helx`); ok {
+		t.Error("expected no node for 'helx'")
+	}
+	if n, ok := trie.Node(``); !ok || n.Size() != trie.Size() || n.Count() != trie.Count() {
+		t.Error("expected the empty prefix to return the root")
+	}
+}
+
+type failingRuneReader struct {
+	r io.RuneReader
+}
+
+func (f failingRuneReader) ReadRune() (rune, int, error) {
+	r, size, err := f.r.ReadRune()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	return r, size, err
+}
+
+func TestAddFromReader(t *testing.T) {
+	trie := NewTrie()
+	trie.MaxDepth = 10
+
+	n, err := trie.AddFromReader(strings.NewReader("hello\nhelp\n\nhyphenation-is-long\nworld\nhello\nwörld"), '\n')
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if n != 5 {
+		t.Errorf("expected 5 strings to be added, found %d", n)
+	}
+
+	expected := []string{`hello`, `help`, `world`, `wörld`}
+	if members := trie.PrefixSearch(``); !reflect.DeepEqual(members, expected) {
+		t.Errorf("expected members %v, found %v", expected, members)
+	}
+	if trie.RefCount(`hello`) != 2 {
+		t.Errorf("expected 'hello' to be added twice, found %d", trie.RefCount(`hello`))
+	}
+
+	trie = NewTrie()
+	n, err = trie.AddFromReader(failingRuneReader{strings.NewReader(`a,b,c`)}, ',')
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected the read error to be returned, found %v", err)
+	}
+	if n != 3 || trie.Count() != 3 {
+		t.Errorf("expected 3 strings before the error, found %d", n)
+	}
+}
+
+func TestAllSuffixes(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`help`, `hello`, `helm`, `hero`, `he`} {
+		trie.AddString(s)
+	}
+
+	// descend one rune at a time, as if typing
+	n, _ := trie.Node(``)
+	for _, r := range `hel` {
+		var ok bool
+		if n, ok = n.Node(string(r)); !ok {
+			t.Fatalf("expected a node for '%c'", r)
+		}
+	}
+
+	expected := []string{`lo`, `m`, `p`}
+	if found := n.AllSuffixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected suffixes %v but found %v", expected, found)
+	}
+
+	// a member node includes itself as the empty suffix
+	n, _ = trie.Node(`he`)
+	expected = []string{``, `llo`, `lm`, `lp`, `ro`}
+	if found := n.AllSuffixes(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected suffixes %v but found %v", expected, found)
+	}
+
+	if found := NewTrie().AllSuffixes(); found == nil || len(found) != 0 {
+		t.Errorf("expected an empty slice but found %v", found)
+	}
+}
+
+func TestEqual(t *testing.T) {
+	build := func() *Trie {
+		trie := NewTrie()
+		trie.AddValue(`hello`, []int32{1, 2})
+		trie.AddString(`help`)
+		trie.AddString(`world`)
+		return trie
+	}
+
+	a, b := build(), build()
+	if !a.Equal(b) || !b.Equal(a) {
+		t.Error("expected identically built tries to be equal")
+	}
+
+	// insertion order doesn't matter
+	c := NewTrie()
+	c.AddString(`world`)
+	c.AddString(`help`)
+	c.AddValue(`hello`, []int32{1, 2})
+	if !a.Equal(c) {
+		t.Error("expected tries built in a different order to be equal")
+	}
+
+	// a different value
+	b.AddValue(`hello`, []int32{1, 3})
+	if a.Equal(b) || b.Equal(a) {
+		t.Error("expected tries with different values to differ")
+	}
+
+	// the same number of children, but with a different rune
+	b = build()
+	b.Remove(`help`)
+	b.AddString(`helm`)
+	if a.Equal(b) || b.Equal(a) {
+		t.Error("expected tries with different child runes to differ")
+	}
+
+	// a prefix which is a member in only one
+	b = build()
+	b.AddString(`hel`)
+	if a.Equal(b) || b.Equal(a) {
+		t.Error("expected tries with different members to differ")
+	}
+}
+
+func TestMembersToDepth(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`a`, `ab`, `abc`, `abcd`, `abé`, `ñu`, `ñandú`, `xyz`} {
+		trie.AddString(s)
+	}
+
+	tests := []struct {
+		depth     int
+		complete  []string
+		truncated []string
+	}{
+		{0, []string{}, []string{}},
+		{1, []string{`a`}, []string{`a`, `x`, `ñ`}},
+		{2, []string{`a`, `ab`, `ñu`}, []string{`ab`, `xy`, `ña`}},
+		{3, []string{`a`, `ab`, `abc`, `abé`, `xyz`, `ñu`}, []string{`abc`, `ñan`}},
+		{5, []string{`a`, `ab`, `abc`, `abcd`, `abé`, `xyz`, `ñandú`, `ñu`}, []string{}},
+	}
+	for _, test := range tests {
+		complete, truncated := trie.MembersToDepth(test.depth)
+		if !reflect.DeepEqual(complete, test.complete) {
+			t.Errorf("depth %d: expected complete %v but found %v", test.depth, test.complete, complete)
+		}
+		if !reflect.DeepEqual(truncated, test.truncated) {
+			t.Errorf("depth %d: expected truncated %v but found %v", test.depth, test.truncated, truncated)
+		}
+	}
+}
+
+func TestLongestCommonPrefix(t *testing.T) {
+	tests := []struct {
+		members  []string
+		expected string
+	}{
+		{[]string{}, ``},
+		{[]string{`hello`}, `hello`},
+		{[]string{`com.example.foo`, `com.example.bar`, `com.example.baz`}, `com.example.`},
+		{[]string{`ñandú`, `ñandúes`}, `ñandú`},
+		{[]string{`hello`, `world`}, ``},
+		{[]string{`a`, `ab`, `abc`}, `a`},
+	}
+	for _, test := range tests {
+		trie := NewTrie()
+		for _, s := range test.members {
+			trie.AddString(s)
+		}
+		if found := trie.LongestCommonPrefix(); found != test.expected {
+			t.Errorf("%v: expected '%s' but found '%s'", test.members, test.expected, found)
+		}
+	}
+}
+
+// walkSize counts the nodes below p, failing if any node's cached size is wrong.
+func walkSize(t *testing.T, p *Trie) int {
+	sz := 0
+	for r, child := range p.children {
+		childSize := walkSize(t, child)
+		if child.rootConfig != nil {
+			t.Errorf("node '%c' has a configuration of its own", r)
+		}
+		if child.Size() != childSize {
+			t.Errorf("node '%c' has a cached size of %d, but %d nodes below it", r, child.Size(), childSize)
+		}
+		sz += childSize + 1
+	}
+	return sz
+}
+
+func checkSize(t *testing.T, trie *Trie, step string) {
+	if sz := walkSize(t, trie); trie.Size() != sz {
+		t.Errorf("%s: expected Size() %d but found %d", step, sz, trie.Size())
+	}
+}
+
+func TestCachedSize(t *testing.T) {
+	trie := NewTrie()
+	words := []string{`hello`, `help`, `hel`, `hello, world!`, `world`, `wörld`, `w`, `hyphenation`}
+
+	// interleave adds and removes, including re-adding members and removing prefixes
+	for i := 0; i < 4*len(words); i++ {
+		s := words[(i*5)%len(words)]
+		if i%3 == 2 {
+			trie.Remove(s)
+			checkSize(t, trie, fmt.Sprintf("remove '%s'", s))
+		} else {
+			trie.AddString(s)
+			checkSize(t, trie, fmt.Sprintf("add '%s'", s))
+		}
+	}
+
+	trie.MaxDepth = 4
+	trie.AddString(`helpless`)
+	checkSize(t, trie, `add past MaxDepth`)
+	trie.MaxDepth = 0
+
+	trie.AddFromRuneReader(strings.NewReader(`helper`))
+	checkSize(t, trie, `AddFromRuneReader`)
+	trie.SetNodeValue(`wax`, 1)
+	checkSize(t, trie, `SetNodeValue`)
+	trie.AddCharValues(`wane`, []int32{1, 2, 3, 4})
+	checkSize(t, trie, `AddCharValues`)
+	trie.RemovePrefixN(`hello`)
+	checkSize(t, trie, `RemovePrefixN`)
+	trie.RemovePrefixExcept(`hel`, `helper`)
+	checkSize(t, trie, `RemovePrefixExcept`)
+
+	other := NewTrie()
+	other.AddValue(`hello`, 1)
+	other.AddValue(`worldly`, 1)
+	trie.Merge(other)
+	checkSize(t, trie, `Merge`)
+	trie.RemoveByValue(1, nil)
+	checkSize(t, trie, `RemoveByValue`)
+
+	checkSize(t, trie.AppendSuffixToAll(`ing`), `AppendSuffixToAll`)
+
+	data, err := trie.GobEncode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded := NewTrie()
+	if err = decoded.GobDecode(data); err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, decoded, `GobDecode`)
+
+	patterns := NewTrie()
+	patterns.AddPatternString(`hy3ph`)
+	patterns.AddPatternString(`he2n`)
+	var buf bytes.Buffer
+	if _, err = patterns.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+	read, err := ReadTrie(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, read, `ReadTrie`)
+
+	chars := NewTrie()
+	chars.AddCharValues(`hyph`, []int32{1, 2, 3, 4})
+	chars.AddCharValues(`hello`, []int32{1, 2, 3, 4, 5})
+	patterns.MergeMax(chars)
+	checkSize(t, patterns, `MergeMax`)
+
+	built, err := BuildFromSortedReaders(strings.NewReader("a\nab\nabc\nabd\nb\nbcd\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkSize(t, built, `BuildFromSortedReaders`)
+
+	trie.RemovePrefixN(``)
+	if trie.Size() != 0 {
+		t.Errorf("expected an empty trie to have size 0, found %d", trie.Size())
+	}
+}
+
+func TestMembersSharedBuffer(t *testing.T) {
+	trie := membersBenchmarkTrie()
+	trie.AddString(`ñandú`)
+	trie.AddString(`ñ`)
+
+	expected := concatMembers(trie, ``)
+	sort.Strings(expected)
+	if found := trie.Members(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %d members matching the concatenated enumeration, found %d", len(expected), len(found))
+	}
+
+	expected = concatMembers(trie.find(`/usr/share/doc/package-001`), `/usr/share/doc/package-001`)
+	sort.Strings(expected)
+	if found := trie.PrefixSearch(`/usr/share/doc/package-001`); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected prefixed members %v, found %v", expected, found)
+	}
+}
+
+func TestAddStringIterative(t *testing.T) {
+	words := []string{`hyphenation`, `hyphen`, `hyphenate`, `ñandú`, `ñu`, `hy`, `hyphenation`}
+
+	iterative, reader := NewTrie(), NewTrie()
+	for _, w := range words {
+		iterative.AddString(w)
+		reader.AddFromRuneReader(strings.NewReader(w))
+	}
+	if !iterative.Equal(reader) || iterative.Size() != reader.Size() {
+		t.Errorf("expected the same trie from both insertion routines, found %v and %v", iterative.Members(), reader.Members())
+	}
+	if iterative.RefCount(`hyphenation`) != 2 {
+		t.Errorf("expected a reference count of 2 for a re-added member, found %d", iterative.RefCount(`hyphenation`))
+	}
+	checkSize(t, iterative, `AddString`)
+
+	// a string longer than MaxDepth leaves the trie untouched, even where it shares a path
+	iterative.MaxDepth = 8
+	if err := iterative.TryAddString(`hyphenations`); err != ErrMaxDepth {
+		t.Errorf("expected ErrMaxDepth, found %v", err)
+	}
+	if err := iterative.TryAddValue(`hyphenator`, 1); err != ErrMaxDepth {
+		t.Errorf("expected ErrMaxDepth, found %v", err)
+	}
+	if !iterative.Equal(reader) {
+		t.Errorf("expected the trie to be unchanged, found %v", iterative.Members())
+	}
+	if err := iterative.TryAddString(`hyphenat`); err != nil || !iterative.Contains(`hyphenat`) {
+		t.Errorf("expected a string of exactly MaxDepth runes to be added, found %v", err)
+	}
+}
+
+func TestRuneHistogram(t *testing.T) {
+	trie := NewTrie()
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+	trie.AddString(`ñoño`)
+
+	// 'h', 'e' and the first 'l' are shared by 'hello' and 'help'
+	expected := map[rune]int{'h': 1, 'e': 1, 'l': 2, 'o': 3, 'p': 1, 'ñ': 2}
+	if found := trie.RuneHistogram(); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	total := 0
+	for _, n := range trie.RuneHistogram() {
+		total += n
+	}
+	if total != trie.Size() {
+		t.Errorf("expected the counts to total %d nodes, found %d", trie.Size(), total)
+	}
+}
+
+func TestHeight(t *testing.T) {
+	trie := NewTrie()
+	if trie.Height() != 0 {
+		t.Errorf("expected an empty trie to have height 0, found %d", trie.Height())
+	}
+
+	longest := 0
+	for _, s := range []string{`hy`, `hyphenation`, `ñandúes`, `hyphen`} {
+		trie.AddString(s)
+		if n := utf8.RuneCountInString(s); n > longest {
+			longest = n
+		}
+		if trie.Height() != longest {
+			t.Errorf("after adding '%s' expected height %d, found %d", s, longest, trie.Height())
+		}
+	}
+
+	trie.Remove(`hyphenation`)
+	if trie.Height() != 7 {
+		t.Errorf("expected height 7 after removing the longest member, found %d", trie.Height())
+	}
+}
+
+func TestClear(t *testing.T) {
+	trie := NewTrie()
+	trie.MaxDepth = 20
+	trie.AddValue(`hello`, 1)
+	trie.AddString(`world`)
+	trie.AddExceptionString(`hy-phen`)
+
+	trie.Clear()
+	if trie.Size() != 0 || trie.Count() != 0 {
+		t.Errorf("expected an empty trie, found %d nodes", trie.Size())
+	}
+	for _, s := range []string{`hello`, `world`} {
+		if trie.Contains(s) {
+			t.Errorf("expected '%s' to be removed", s)
+		}
+	}
+	if trie.KeysWithMinValue(0) == nil || len(trie.KeysWithMinValue(0)) != 0 {
+		t.Errorf("expected no keys with values, found %v", trie.KeysWithMinValue(0))
+	}
+	if !reflect.DeepEqual(trie.Hyphenate(`hyphen`), []string{`hyphen`}) {
+		t.Errorf("expected exceptions to be removed, found %v", trie.Hyphenate(`hyphen`))
+	}
+	if trie.MaxDepth != 20 {
+		t.Errorf("expected MaxDepth to be kept, found %d", trie.MaxDepth)
+	}
+
+	// the same trie can be reused
+	trie.AddString(`again`)
+	if !reflect.DeepEqual(trie.MembersByInsertionOrder(), []string{`again`}) || trie.Size() != 5 {
+		t.Errorf("expected only 'again' after reuse, found %v", trie.Members())
+	}
+}
+
+func TestTrieFold(t *testing.T) {
+	trie := NewTrieFold()
+	trie.AddString(`Hello`)
+	trie.AddString(`hello`)
+	trie.AddValue(`WORLD`, 1)
+	trie.AddString(`İstanbul`)
+
+	if !reflect.DeepEqual(trie.Members(), []string{`hello`, `istanbul`, `world`}) {
+		t.Errorf("expected folded members, found %v", trie.Members())
+	}
+	for _, s := range []string{`hello`, `HELLO`, `hElLo`, `World`, `istanbul`, `İSTANBUL`} {
+		if !trie.Contains(s) {
+			t.Errorf("expected '%s' to be found regardless of case", s)
+		}
+	}
+	if v, ok := trie.GetValue(`world`); !ok || v != 1 {
+		t.Errorf("expected (1, true) for 'world', found (%v, %v)", v, ok)
+	}
+	if trie.RefCount(`HELLO`) != 2 {
+		t.Errorf("expected 'Hello' and 'hello' to collide, found a count of %d", trie.RefCount(`HELLO`))
+	}
+	if !trie.ContainsPrefix(`İST`) || !reflect.DeepEqual(trie.PrefixSearch(`HEL`), []string{`hello`}) {
+		t.Errorf("expected prefixes to be folded, found %v", trie.PrefixSearch(`HEL`))
+	}
+
+	trie.Remove(`HELLO`)
+	trie.Remove(`Hello`)
+	if trie.Contains(`hello`) {
+		t.Error("expected 'hello' to be removed regardless of case")
+	}
+
+	// a plain trie is case-sensitive
+	plain := NewTrie()
+	plain.AddString(`Hello`)
+	if plain.Contains(`hello`) {
+		t.Error("expected a plain trie to distinguish case")
+	}
+
+	trie.Clear()
+	trie.AddString(`ABC`)
+	if !trie.Contains(`abc`) {
+		t.Error("expected Clear to keep case-insensitivity")
+	}
+
+	// functions which walk the path of a string also fold it
+	trie.AddCharValues(`abd`, []int32{1, 2, 3})
+	if vals, ok := trie.CharValues(`ABD`); !ok || !reflect.DeepEqual(vals, []int32{1, 2, 3}) {
+		t.Errorf("expected ([1 2 3], true) for 'ABD', found (%v, %v)", vals, ok)
+	}
+	if n := trie.RemovePrefixExcept(`A`, `Ab`); n != 2 || trie.Count() != 0 {
+		t.Errorf("expected both members to be removed, found %d removed and %v left", n, trie.Members())
+	}
+	trie.AddAll([]string{`abc`, `abd`})
+	if n := trie.RemovePrefixExcept(`A`, `ABC`); n != 1 || !reflect.DeepEqual(trie.Members(), []string{`abc`}) {
+		t.Errorf("expected only 'abc' to be kept, found %d removed and %v left", n, trie.Members())
+	}
+	checkSize(t, trie, `RemovePrefixExcept`)
+
+	// every other function taking a string folds it too
+	trie.Clear()
+	trie.AppendInt(`Foo`, 1)
+	if ids, ok := trie.Ints(`FOO`); !ok || !reflect.DeepEqual(ids, []int{1}) {
+		t.Errorf("expected ([1], true) for 'FOO', found (%v, %v)", ids, ok)
+	}
+	trie.AddValue(`hello`, 2)
+	trie.AddValue(`help`, 3)
+	if found := trie.PrefixSearchN(`HE`, 0); !reflect.DeepEqual(found, []string{`hello`, `help`}) {
+		t.Errorf("expected [hello help] for 'HE', found %v", found)
+	}
+	if key, _, ok := trie.BestUnderPrefix(`HEL`); !ok || key != `help` {
+		t.Errorf("expected 'help' to be best under 'HEL', found '%s'", key)
+	}
+	if found := trie.TopCompletions(`HEL`, 1); !reflect.DeepEqual(found, []string{`help`}) {
+		t.Errorf("expected the top completion [help], found %v", found)
+	}
+	if runes, ok := trie.ChildRunes(`HEL`); !ok || !reflect.DeepEqual(runes, []rune{'l', 'p'}) {
+		t.Errorf("expected the runes [l p] after 'HEL', found %q", runes)
+	}
+	if n, ok := trie.Node(`HELL`); !ok || !reflect.DeepEqual(n.AllSuffixes(), []string{`o`}) {
+		t.Error("expected to find the node for 'HELL'")
+	}
+	if !trie.WalkFrom(`HE`, func(string, interface{}, bool) bool { return true }) {
+		t.Error("expected to walk from 'HE'")
+	}
+	if m, _, ok := trie.LongestPrefixMatch(`HELPER`); !ok || m != `help` {
+		t.Errorf("expected 'help' to match 'HELPER', found '%s'", m)
+	}
+	if found := trie.AllSubstrings(`HELLOS`); !reflect.DeepEqual(found, []string{`hello`}) {
+		t.Errorf("expected [hello] within 'HELLOS', found %v", found)
+	}
+	if !trie.SetNodeValue(`HE`, `he`) {
+		t.Error("expected to set the value of 'HE'")
+	}
+	if v, ok := trie.GetNodeValue(`he`); !ok || v != `he` {
+		t.Errorf("expected (he, true) for the node 'he', found (%v, %v)", v, ok)
+	}
+	if n := trie.DeleteSubtree(`HEL`); n != 2 || trie.Count() != 1 {
+		t.Errorf("expected 2 members removed below 'HEL', found %d", n)
+	}
+	checkSize(t, trie, `DeleteSubtree`)
+
+	// rune readers are folded as they are read
+	trie.AddFromRuneReader(strings.NewReader(`Hello`))
+	if !trie.Contains(`hello`) || !trie.ContainsFromRuneReader(strings.NewReader(`HELLO`)) {
+		t.Errorf("expected 'Hello' to be folded when read, found %v", trie.Members())
+	}
+	if n, err := trie.AddFromReader(strings.NewReader("World\nWIDE"), '\n'); n != 2 || err != nil {
+		t.Errorf("expected 2 strings read, found %d and error %v", n, err)
+	}
+	if !reflect.DeepEqual(trie.Members(), []string{`foo`, `hello`, `wide`, `world`}) {
+		t.Errorf("expected folded members, found %v", trie.Members())
+	}
+
+	// merged members are folded as they are added
+	plain.AddValue(`Banana`, 9)
+	plain.AddValue(`WORLD`, 10)
+	trie.Merge(plain)
+	if !reflect.DeepEqual(trie.Members(), []string{`banana`, `foo`, `hello`, `wide`, `world`}) {
+		t.Errorf("expected folded members after merging, found %v", trie.Members())
+	}
+	if v, ok := trie.GetValue(`Banana`); !ok || v != 9 {
+		t.Errorf("expected (9, true) for 'Banana', found (%v, %v)", v, ok)
+	}
+	if v, _ := trie.GetValue(`world`); v != 10 || trie.RefCount(`world`) != 1 {
+		t.Errorf("expected the merged value to replace that of 'world', found %v", v)
+	}
+	checkSize(t, trie, `Merge`)
+	checkMaxValue(t, trie)
+
+	// and a Matcher inherits the folding
+	if found := NewMatcher(trie).FindAll(`Hello, World`); len(found) != 2 {
+		t.Errorf("expected 'Hello' and 'World' to be matched, found %v", found)
+	}
+}
+
+func TestDeleteSubtree(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`app/main`, `app/lib/util`, `app/`, `apt/get`, `apt/cache`, `ap`} {
+		trie.AddString(s)
+	}
+
+	if n := trie.DeleteSubtree(`app/`); n != 3 {
+		t.Errorf("expected 3 keys removed, found %d", n)
+	}
+	expected := []string{`ap`, `apt/cache`, `apt/get`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, trie.Members())
+	}
+	checkSize(t, trie, `DeleteSubtree`)
+
+	if n := trie.DeleteSubtree(`app/`); n != 0 {
+		t.Errorf("expected nothing removed for a missing prefix, found %d", n)
+	}
+	if n := trie.DeleteSubtree(`apt/`); n != 2 || trie.Size() != 2 {
+		t.Errorf("expected the empty 'apt/' chain to be pruned, found %d removed and %d nodes", n, trie.Size())
+	}
+}
+
+func TestRetain(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`app/main`, `app/lib/util`, `apt/get`, `apt/cache`, `bin/ls`, `etc/hosts`, `ap`} {
+		trie.AddString(s)
+	}
+
+	// 'app/lib/util' matches two prefixes; 'bin/ls' and 'ap' match none
+	if n := trie.Retain([]string{`app/`, `app/lib`, `apt/get`, `etc/`, `var/`}); n != 3 {
+		t.Errorf("expected 3 keys removed, found %d", n)
+	}
+	expected := []string{`app/lib/util`, `app/main`, `apt/get`, `etc/hosts`}
+	if !reflect.DeepEqual(trie.Members(), expected) {
+		t.Errorf("expected members %v, found %v", expected, trie.Members())
+	}
+	checkSize(t, trie, `Retain`)
+	if trie.ContainsPrefix(`bin`) {
+		t.Error("expected the path to 'bin/ls' to be pruned")
+	}
+
+	if n := trie.Retain([]string{``}); n != 0 {
+		t.Errorf("expected the empty prefix to keep everything, found %d removed", n)
+	}
+	if n := trie.Retain(nil); n != 4 || trie.Size() != 0 {
+		t.Errorf("expected no prefixes to remove everything, found %d removed and %d nodes", n, trie.Size())
+	}
+}
+
+func TestAllSubstringsAgree(t *testing.T) {
+	trie := NewTrie()
+	for _, s := range []string{`h`, `hy`, `hyph`, `hyphen`, `ñ`, `ña`, `ñandú`, `日本`} {
+		trie.AddString(s)
+	}
+
+	for _, s := range []string{`hyphenation`, `hyph`, `ñandú`, `ñandúes`, `日本語`, `x`, ``} {
+		strs, _ := trie.AllSubstringsAndValues(s)
+		if found := trie.AllSubstrings(s); !reflect.DeepEqual(found, strs) {
+			t.Errorf("'%s': AllSubstrings found %v but AllSubstringsAndValues found %v", s, found, strs)
+		}
+		if found := trie.AllSubstringsN(s, 0); !reflect.DeepEqual(found, strs) {
+			t.Errorf("'%s': AllSubstringsN found %v but AllSubstringsAndValues found %v", s, found, strs)
+		}
+		for _, m := range strs {
+			if !strings.HasPrefix(s, m) || !trie.Contains(m) {
+				t.Errorf("'%s': found '%s', which is not a member prefix", s, m)
+			}
+		}
+	}
+}
+
+func TestStats(t *testing.T) {
+	trie := NewTrie()
+	if stats := trie.Stats(); stats != (TrieStats{}) {
+		t.Errorf("expected empty stats for an empty trie, found %+v", stats)
+	}
+
+	trie.AddString(`he`)
+	trie.AddString(`hello`)
+	trie.AddString(`help`)
+	trie.AddString(`ñu`)
+
+	// h-e-l-l-o, p, ñ-u; member depths 2, 5, 4 & 2
+	expected := TrieStats{Nodes: 8, Leaves: 4, MaxDepth: 5, AvgDepth: 3.25}
+	stats := trie.Stats()
+	if stats != expected {
+		t.Errorf("expected %+v but found %+v", expected, stats)
+	}
+	if stats.Nodes != trie.Size() || stats.Leaves != trie.Count() || stats.MaxDepth != trie.Height() {
+		t.Errorf("expected stats to agree with Size, Count & Height, found %+v", stats)
+	}
+}
+
+func TestValuesUnder(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`hyph`, []int32{0, 3, 0, 0})
+	trie.AddValue(`hen`, []int32{2, 0, 0})
+	trie.AddValue(`hena`, []int32{0, 0, 0, 4})
+	trie.AddString(`henat`)
+	trie.AddValue(`tio`, []int32{1, 0, 0})
+
+	expected := []interface{}{[]int32{2, 0, 0}, []int32{0, 0, 0, 4}, []int32{0, 3, 0, 0}}
+	if found := trie.ValuesUnder(`h`); !reflect.DeepEqual(found, expected) {
+		t.Errorf("expected %v but found %v", expected, found)
+	}
+
+	// the values follow the order of PrefixSearch
+	keys := trie.PrefixSearch(`hen`)
+	values := trie.ValuesUnder(`hen`)
+	for i, key := range keys[:len(values)] {
+		if v, _ := trie.GetValue(key); !reflect.DeepEqual(v, values[i]) {
+			t.Errorf("expected value %v for '%s', found %v", v, key, values[i])
+		}
+	}
+
+	if found := trie.ValuesUnder(`x`); found == nil || len(found) != 0 {
+		t.Errorf("expected an empty slice for a missing prefix, found %v", found)
+	}
+}
+
+// composes the few decomposed sequences used by TestTrieNormalized, standing in for norm.NFC
+type composer struct{}
+
+func (composer) String(s string) string {
+	return strings.NewReplacer("é", "é", "ñ", "ñ").Replace(s)
+}
+
+func TestTrieNormalized(t *testing.T) {
+	trie := NewTrieNormalized(composer{})
+	trie.AddString("café")
+	trie.AddValue("ñandú", 1)
+
+	if !trie.Contains("café") {
+		t.Error("expected the decomposed form of 'café' to be found")
+	}
+	if v, ok := trie.GetValue("ñandú"); !ok || v != 1 {
+		t.Errorf("expected (1, true) for a mixed form of 'ñandú', found (%v, %v)", v, ok)
+	}
+	if !reflect.DeepEqual(trie.Members(), []string{"café", "ñandú"}) {
+		t.Errorf("expected members in their normal forms, found %q", trie.Members())
+	}
+
+	trie.AddString("café")
+	if trie.Count() != 2 || trie.RefCount("café") != 2 {
+		t.Errorf("expected both forms of 'café' to be the same member, found %q", trie.Members())
+	}
+
+	if NewTrie().Contains("café") {
+		t.Error("expected a plain trie to be empty")
+	}
+}
+
+func TestAddAll(t *testing.T) {
+	trie := NewTrie()
+	words := []string{`one`, `two`, `three`, `four`, `five`, `six`, `seven`, `eight`, `nine`, `ten`, `eleven`, `twelve`}
+	trie.AddAll(words)
+	if trie.Count() != 12 {
+		t.Errorf("expected 12 members, found %d", trie.Count())
+	}
+
+	values := make([]interface{}, len(words))
+	for i := range values {
+		values[i] = i + 1
+	}
+	trie.AddAllValues(words, values)
+	if trie.Count() != 12 {
+		t.Errorf("expected still 12 members, found %d", trie.Count())
+	}
+	if v, _ := trie.GetValue(`twelve`); v != 12 {
+		t.Errorf("expected 12 for 'twelve', found %v", v)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected AddAllValues to panic on mismatched lengths")
+		}
+	}()
+	trie.AddAllValues(words, values[1:])
+}
+
+func TestShortestPrefixMatch(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`a`, 1)
+	trie.AddValue(`ab`, 2)
+	trie.AddValue(`abc`, 3)
+	trie.AddValue(`ñandú`, 4)
+
+	tests := []struct {
+		s     string
+		match string
+		value interface{}
+		ok    bool
+	}{
+		{`abcd`, `a`, 1, true},
+		{`abc`, `a`, 1, true},
+		{`a`, `a`, 1, true},
+		{`ñandúes`, `ñandú`, 4, true},
+		{`ñan`, ``, nil, false},
+		{`b`, ``, nil, false},
+		{``, ``, nil, false},
+	}
+	for _, test := range tests {
+		match, value, ok := trie.ShortestPrefixMatch(test.s)
+		if match != test.match || value != test.value || ok != test.ok {
+			t.Errorf("'%s': expected (%s, %v, %v) but found (%s, %v, %v)",
+				test.s, test.match, test.value, test.ok, match, value, ok)
+		}
+	}
+
+	if match, _, _ := trie.LongestPrefixMatch(`abcd`); match != `abc` {
+		t.Errorf("expected the longest match to differ, found '%s'", match)
+	}
+}
+
+func TestNodeAccessors(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`he`, 1)
+	trie.AddValue(`hello`, 2)
+	trie.AddString(`hey`)
+	trie.AddString(`ñu`)
+
+	if !reflect.DeepEqual(trie.Children(), []rune{'h', 'ñ'}) {
+		t.Errorf("expected the root's children [h ñ], found %q", trie.Children())
+	}
+
+	// walk to 'he' a rune at a time
+	n, _ := trie.Node(``)
+	for _, r := range `he` {
+		var ok bool
+		if n, ok = n.Child(r); !ok {
+			t.Fatalf("expected a child for '%c'", r)
+		}
+	}
+	if !n.IsLeaf() || n.Value() != 1 {
+		t.Errorf("expected 'he' to be a leaf with value 1, found %v and %v", n.IsLeaf(), n.Value())
+	}
+	if !reflect.DeepEqual(n.Children(), []rune{'l', 'y'}) {
+		t.Errorf("expected the children of 'he' to be [l y], found %q", n.Children())
+	}
+
+	n, _ = n.Child('l')
+	if n.IsLeaf() || n.Value() != nil {
+		t.Error("expected 'hel' not to be a leaf")
+	}
+	if _, ok := n.Child('x'); ok {
+		t.Error("expected no child for 'x'")
+	}
+	if len(NewTrie().Children()) != 0 {
+		t.Error("expected an empty trie to have no children")
+	}
+}
+
+func TestNodeViews(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`abc`, 1)
+
+	// changes made through the root after taking a view must show up in both
+	n, _ := trie.Node(`ab`)
+	a, _ := trie.Child('a')
+	trie.AddString(`xyz`)
+	trie.AddValue(`abzz`, 100)
+	trie.AddValue(`ab`, 50)
+	checkSize(t, trie, "adding below a view")
+	if checkMaxValue(t, trie) != 100 {
+		t.Errorf("expected a maxValue of 100, found %d", trie.maxValue)
+	}
+	if found := trie.KeysWithMinValue(50); !reflect.DeepEqual(found, []string{`ab`, `abzz`}) {
+		t.Errorf("expected [ab abzz] with values of at least 50, found %v", found)
+	}
+
+	if found := n.Members(); !reflect.DeepEqual(found, []string{``, `c`, `zz`}) {
+		t.Errorf("expected the view of 'ab' to see [ c zz], found %v", found)
+	}
+	if !n.IsLeaf() || n.Value() != 50 || !n.Contains(``) {
+		t.Error("expected the view of 'ab' to see it become a member")
+	}
+	if n.Size() != 3 || a.Size() != 4 || a.Count() != 3 {
+		t.Errorf("expected views of sizes 3 and 4, found %d and %d", n.Size(), a.Size())
+	}
+	if found := a.PrefixSearch(`bz`); !reflect.DeepEqual(found, []string{`bzz`}) {
+		t.Errorf("expected [bzz] below 'a', found %v", found)
+	}
+
+	trie.Remove(`abzz`)
+	checkSize(t, trie, "removing below a view")
+	checkMaxValue(t, trie)
+	if n.Contains(`zz`) || n.Size() != 1 {
+		t.Error("expected the view of 'ab' to see 'abzz' removed")
+	}
+
+	// views apply the trie's folding to the strings they're given
+	folded := NewTrieFold()
+	folded.AddValue(`Hello`, 1)
+	h, _ := folded.Child('h')
+	if v, ok := h.GetValue(`ELLO`); !ok || v != 1 {
+		t.Errorf("expected (1, true) for 'ELLO' below 'h', found (%v, %v)", v, ok)
+	}
+	if _, ok := h.Node(`EL`); !ok {
+		t.Error("expected a node for 'EL' below 'h'")
+	}
+}
+
+func TestWith(t *testing.T) {
+	old := NewTrie()
+	old.AddValue(`hello`, 1)
+	old.AddValue(`help`, 2)
+	old.AddString(`world`)
+
+	updated := old.With(`helm`, 3)
+	if old.Contains(`helm`) {
+		t.Error("expected the original trie not to see the new member")
+	}
+	if !reflect.DeepEqual(old.Members(), []string{`hello`, `help`, `world`}) || old.Size() != 11 {
+		t.Errorf("expected the original trie to be unchanged, found %v", old.Members())
+	}
+	if v, ok := updated.GetValue(`helm`); !ok || v != 3 {
+		t.Errorf("expected (3, true) for 'helm' in the new version, found (%v, %v)", v, ok)
+	}
+	if !reflect.DeepEqual(updated.Members(), []string{`hello`, `helm`, `help`, `world`}) {
+		t.Errorf("expected the new version to have all members, found %v", updated.Members())
+	}
+	checkSize(t, updated, `With`)
+
+	// untouched branches are shared, and the path is copied
+	if old.children['w'] != updated.children['w'] {
+		t.Error("expected the 'world' branch to be shared")
+	}
+	if old.children['h'] == updated.children['h'] {
+		t.Error("expected the 'h' node to be copied")
+	}
+	if old.find(`help`) != updated.find(`help`) {
+		t.Error("expected the 'p' node to be shared")
+	}
+
+	// but the versions' configurations are not
+	updated.MaxDepth = 4
+	if old.MaxDepth != 0 || old.rootConfig == updated.rootConfig {
+		t.Error("expected the versions to have separate configurations")
+	}
+	updated.MaxDepth = 0
+
+	// replacing a value
+	again := updated.With(`hello`, 10)
+	if v, _ := updated.GetValue(`hello`); v != 1 {
+		t.Errorf("expected the previous version to keep its value, found %v", v)
+	}
+	if v, _ := again.GetValue(`hello`); v != 10 || again.Size() != updated.Size() {
+		t.Errorf("expected the value to be replaced without new nodes, found %v", v)
+	}
+	if keys := again.KeysWithMinValue(10); !reflect.DeepEqual(keys, []string{`hello`}) {
+		t.Errorf("expected KeysWithMinValue to see the new value, found %v", keys)
+	}
+	if keys := updated.KeysWithMinValue(10); len(keys) != 0 {
+		t.Errorf("expected KeysWithMinValue on the previous version to find nothing, found %v", keys)
+	}
+}
+
+func TestTopCompletions(t *testing.T) {
+	trie := NewTrie()
+	frequencies := map[string]int{
+		`the`: 500, `then`: 120, `there`: 300, `these`: 120, `they`: 400, `theme`: 10, `tea`: 1000,
+	}
+	for s, f := range frequencies {
+		trie.AddValue(s, f)
+	}
+	trie.AddString(`thesaurus`)
+
+	tests := []struct {
+		prefix   string
+		k        int
+		expected []string
+	}{
+		{`the`, 3, []string{`the`, `they`, `there`}},
+		{`the`, 5, []string{`the`, `they`, `there`, `then`, `these`}},
+		{`the`, 10, []string{`the`, `they`, `there`, `then`, `these`, `theme`}},
+		{`t`, 1, []string{`tea`}},
+		{`thes`, 2, []string{`these`}},
+		{`x`, 2, []string{}},
+		{`the`, 0, []string{}},
+	}
+	for _, test := range tests {
+		found := trie.TopCompletions(test.prefix, test.k)
+		if !reflect.DeepEqual(found, test.expected) {
+			t.Errorf("'%s', %d: expected %v but found %v", test.prefix, test.k, test.expected, found)
+		}
+	}
+}
+
+func TestCountPrefix(t *testing.T) {
+	trie := NewTrie()
+	trie.AddAll([]string{`app`, `apple`, `applet`, `apply`, `apt`, `banana`, `band`, `ñu`})
+
+	tests := map[string]int{
+		``:       8,
+		`a`:      5,
+		`app`:    4,
+		`appl`:   3,
+		`apple`:  2,
+		`apt`:    1,
+		`ban`:    2,
+		`ñ`:      1,
+		`cherry`: 0,
+		`apples`: 0,
+	}
+	for prefix, expected := range tests {
+		if found := trie.CountPrefix(prefix); found != expected {
+			t.Errorf("'%s': expected %d but found %d", prefix, expected, found)
+		}
+		if found := len(trie.PrefixSearch(prefix)); found != expected {
+			t.Errorf("'%s': expected PrefixSearch to agree, found %d", prefix, found)
+		}
+	}
+}
+
+func TestAddRunes(t *testing.T) {
+	words := []string{`hyphenation`, `hyphen`, `ñandú`, `日本語`, `hyphen`, ``}
+
+	fromStrings, fromRunes := NewTrie(), NewTrie()
+	for _, w := range words {
+		fromStrings.AddString(w)
+		fromRunes.AddRunes([]rune(w))
+	}
+	if !fromRunes.Equal(fromStrings) {
+		t.Errorf("expected the same trie, found %v and %v", fromRunes.Members(), fromStrings.Members())
+	}
+	checkSize(t, fromRunes, `AddRunes`)
+	if fromRunes.RefCount(`hyphen`) != 2 || fromRunes.Count() != 4 {
+		t.Errorf("expected 'hyphen' to be counted twice, found %d", fromRunes.RefCount(`hyphen`))
+	}
+
+	for _, w := range []string{`hyphen`, `ñandú`, `日本語`, `hyph`, `hyphens`, ``} {
+		if fromRunes.ContainsRunes([]rune(w)) != fromStrings.Contains(w) {
+			t.Errorf("'%s': expected ContainsRunes to agree with Contains", w)
+		}
+	}
+
+	fromRunes.MaxDepth = 3
+	fromRunes.AddRunes([]rune(`abcd`))
+	if fromRunes.ContainsRunes([]rune(`abcd`)) || fromRunes.ContainsPrefix(`a`) {
+		t.Error("expected a string longer than MaxDepth to be ignored")
+	}
+
+	folded := NewTrieFold()
+	folded.AddRunes([]rune(`Hello`))
+	if !folded.ContainsRunes([]rune(`HELLO`)) || !folded.Contains(`hello`) {
+		t.Error("expected AddRunes to fold case in a case-insensitive trie")
+	}
+}
+
+func TestEstimatedBytes(t *testing.T) {
+	trie := NewTrie()
+	last := trie.EstimatedBytes()
+	if last <= 0 {
+		t.Fatalf("expected an empty trie to take some space, found %d", last)
+	}
+
+	for _, s := range []string{`hello`, `help`, `hello, world!`, `ñandú`} {
+		trie.AddString(s)
+		if n := trie.EstimatedBytes(); n <= last {
+			t.Errorf("after adding '%s' expected more than %d bytes, found %d", s, last, n)
+		} else {
+			last = n
+		}
+	}
+
+	// a value adds to the estimate, and re-adding a member doesn't
+	trie.AddValue(`help`, []int32{1, 2, 3, 4})
+	if n := trie.EstimatedBytes(); n <= last {
+		t.Errorf("expected a value to add to the estimate, found %d", n)
+	} else {
+		last = n
+	}
+	trie.AddString(`hello`)
+	if n := trie.EstimatedBytes(); n != last {
+		t.Errorf("expected re-adding a member to leave the estimate at %d, found %d", last, n)
+	}
+}
+
+func TestFindPrefixesOf(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`h`, []int32{1})
+	trie.AddValue(`hy`, []int32{0, 3})
+	trie.AddValue(`hyph`, []int32{0, 3, 0, 0})
+	trie.AddString(`hyphen`)
+	trie.AddValue(`ñ`, 1)
+	trie.AddValue(`ñandú`, 5)
+
+	tests := map[string][]string{
+		`hyphenation`: {`h`, `hy`, `hyph`, `hyphen`},
+		`hyphen`:      {`h`, `hy`, `hyph`, `hyphen`},
+		`hyp`:         {`h`, `hy`},
+		`ñandú`:       {`ñ`, `ñandú`},
+		`ñandúes`:     {`ñ`, `ñandú`},
+		`x`:           {},
+	}
+	for s, expected := range tests {
+		if found := trie.FindPrefixesOf(s); !reflect.DeepEqual(found, expected) {
+			t.Errorf("'%s': expected %v but found %v", s, expected, found)
+		}
+	}
+
+	strs, values := trie.FindPrefixesOfWithValues(`hyphenation`)
+	expectedValues := []interface{}{[]int32{1}, []int32{0, 3}, []int32{0, 3, 0, 0}, nil}
+	if !reflect.DeepEqual(strs, tests[`hyphenation`]) || !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("expected %v with values %v, found %v with %v", tests[`hyphenation`], expectedValues, strs, values)
+	}
+	strs, values = trie.FindPrefixesOfWithValues(`ñandú`)
+	if !reflect.DeepEqual(strs, []string{`ñ`, `ñandú`}) || !reflect.DeepEqual(values, []interface{}{1, 5}) {
+		t.Errorf("expected [ñ ñandú] with values [1 5], found %v with %v", strs, values)
+	}
+}
+
+func TestWalkFrom(t *testing.T) {
+	trie := NewTrie()
+	trie.AddValue(`app/main`, 1)
+	trie.AddValue(`app/lib`, 2)
+	trie.AddValue(`app/libx`, 3)
+	trie.AddValue(`apt/get`, 4)
+
+	members := []string{}
+	visited := 0
+	ok := trie.WalkFrom(`app/`, func(key string, value interface{}, isLeaf bool) bool {
+		visited++
+		if isLeaf {
+			members = append(members, fmt.Sprintf("%s=%v", key, value))
+		}
+		return key != `app/lib`
+	})
+	if !ok {
+		t.Error("expected the prefix 'app/' to be found")
+	}
+
+	// 'app/libx' is below the pruned 'app/lib'
+	expected := []string{`app/lib=2`, `app/main=1`}
+	if !reflect.DeepEqual(members, expected) {
+		t.Errorf("expected %v but found %v", expected, members)
+	}
+	// app/, l, i, b, m, a, i, n
+	if visited != 8 {
+		t.Errorf("expected 8 nodes visited, found %d", visited)
+	}
+
+	called := false
+	if trie.WalkFrom(`apx`, func(string, interface{}, bool) bool { called = true; return true }) || called {
+		t.Error("expected a missing prefix to return false without calling fn")
+	}
+
+	// an empty prefix behaves as Walk
+	all, walked := 0, 0
+	trie.WalkFrom(``, func(string, interface{}, bool) bool { all++; return true })
+	trie.Walk(func(string, interface{}, bool) bool { walked++; return true })
+	if all != walked || all != trie.Size() {
+		t.Errorf("expected %d nodes from both walks, found %d and %d", trie.Size(), all, walked)
+	}
+}
+
+//////////////////////////////////////////////////////////////////
+// Benchmarks
+// Run like so:
+//   cat patterns-en.go | gotest -benchmarks=".*"
+// This is because, for some unknown reason, os.Open() always returns 'resource temporarily unavailable'.
+
+var benchmarkTrie *Trie
+
+func setupTrie() *Trie {
+	/*
+		filename := "patterns-en.go"
+		f, err := os.Open(filename, 0444, os.O_RDONLY)
+		if err != nil {
+			fmt.Printf("Failed to open file '%s': %s\n", filename, err)
+		}
+	*/
+	if benchmarkTrie == nil {
+		var err error
+		benchmarkTrie, err = LoadTeXPatterns(os.Stdin)
+		if err != nil {
+			fmt.Printf("Failed to load patterns from Stdin: %s\n", err)
+		}
+	}
+	return benchmarkTrie
+}
+
+func BenchmarkTraversal(b *testing.B) {
+	b.StopTimer()
+	trie := setupTrie()
+	if trie == nil {
+		return
+	}
+	b.StartTimer()
+
+	for i := 0; i < b.N; i++ {
+		trie.Members()
+	}
+}
+
+// the member enumeration used before buildMembers shared a buffer, for comparison
+func concatMembers(p *Trie, prefix string) []string {
+	strList := []string{}
+	if p.leaf {
+		strList = append(strList, prefix)
+	}
+	for r, child := range p.children {
+		buf := make([]byte, 4)
+		numChars := utf8.EncodeRune(buf, r)
+		strList = append(strList, concatMembers(child, prefix+string(buf[0:numChars]))...)
+	}
+	return strList
+}
+
+func membersBenchmarkTrie() *Trie {
+	trie := NewTrie()
+	for i := 0; i < 5000; i++ {
+		trie.AddString(fmt.Sprintf("/usr/share/doc/package-%04d/README-ñ%d", i, i%7))
+	}
+	return trie
+}
+
+func BenchmarkMembers(b *testing.B) {
+	trie := membersBenchmarkTrie()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		trie.buildMembers(``)
+	}
+}
+
+func BenchmarkMembersConcat(b *testing.B) {
+	trie := membersBenchmarkTrie()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		concatMembers(trie, ``)
+	}
+}
+
+// the pattern strings of the en-US pattern set, with their digits removed
+func patternWords(b *testing.B) []string {
+	f, err := os.Open(`patterns-en`)
+	if err != nil {
+		b.Skipf("Failed to open pattern file: %s", err)
+	}
+	defer f.Close()
+
+	trie, err := LoadTeXPatterns(f)
+	if err != nil {
+		b.Fatalf("Failed to load patterns: %s", err)
+	}
+	return trie.Members()
+}
+
+func BenchmarkAddString(b *testing.B) {
+	words := patternWords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		trie := NewTrie()
+		for _, w := range words {
+			trie.AddString(w)
+		}
+	}
+}
+
+// loading through a RuneReader, as AddString did before it iterated over the string itself
+func BenchmarkAddFromRuneReader(b *testing.B) {
+	words := patternWords(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		trie := NewTrie()
+		for _, w := range words {
+			trie.AddFromRuneReader(strings.NewReader(w))
+		}
 	}
 }
 
@@ -387,7 +2913,7 @@ func BenchmarkHyphenation(b *testing.B) {
 		vIndex := 0
 		for pos := range testStr {
 			t := testStr[pos:]
-			strs, values := trie.AllSubstringsAndValues(t)
+			strs, values := trie.AllSubstringsWithValues(t)
 			for i := 0; i < len(values); i++ {
 				str := strs[i]
 				val := values[i].([]int32)